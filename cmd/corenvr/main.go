@@ -11,10 +11,15 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/mmuteeullah/CoreNVR/internal/catalog"
 	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/detect"
+	"github.com/mmuteeullah/CoreNVR/internal/events"
 	"github.com/mmuteeullah/CoreNVR/internal/recorder"
 	"github.com/mmuteeullah/CoreNVR/internal/recovery"
+	"github.com/mmuteeullah/CoreNVR/internal/rtsp"
 	"github.com/mmuteeullah/CoreNVR/internal/storage"
+	"github.com/mmuteeullah/CoreNVR/internal/supervisor"
 	"github.com/mmuteeullah/CoreNVR/internal/webui"
 )
 
@@ -27,6 +32,7 @@ func main() {
 	configPath := flag.String("config", "/etc/corenvr/config.yaml", "Path to configuration file")
 	showVersion := flag.Bool("version", false, "Show version and exit")
 	testPlug := flag.String("test-plug", "", "Test smart plug: on|off|status|cycle")
+	rebuildCatalog := flag.Bool("rebuild-catalog", false, "Rebuild the segment catalog from disk and exit")
 	flag.Parse()
 
 	if *showVersion {
@@ -59,6 +65,38 @@ func main() {
 		log.Fatalf("Failed to create storage directory: %v", err)
 	}
 
+	// Load the segment catalog
+	segmentCatalog, err := catalog.Open(cfg.Storage.BasePath)
+	if err != nil {
+		log.Fatalf("Failed to open segment catalog: %v", err)
+	}
+
+	if *rebuildCatalog {
+		for _, cam := range cfg.Cameras {
+			if err := segmentCatalog.Rebuild(cam.Name); err != nil {
+				log.Fatalf("Failed to rebuild catalog for %s: %v", cam.Name, err)
+			}
+		}
+		log.Println("Catalog rebuild complete")
+		os.Exit(0)
+	}
+
+	// Pick up segments left behind by the previous run. Cameras without a
+	// catalog are rebuilt in the background since that probes every file.
+	for _, cam := range cfg.Cameras {
+		if segmentCatalog.HasCamera(cam.Name) {
+			if err := segmentCatalog.Sync(cam.Name); err != nil {
+				log.Printf("WARNING: Failed to sync catalog for %s: %v", cam.Name, err)
+			}
+		} else {
+			go func(name string) {
+				if err := segmentCatalog.Rebuild(name); err != nil {
+					log.Printf("WARNING: Failed to rebuild catalog for %s: %v", name, err)
+				}
+			}(cam.Name)
+		}
+	}
+
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -72,43 +110,60 @@ func main() {
 	if cfg.Recovery.Enabled && cfg.Recovery.SlackWebhook != "" {
 		slackWebhook = cfg.Recovery.SlackWebhook
 	}
-	cleaner := storage.NewCleaner(cfg.Storage, slackWebhook)
+	cleaner := storage.NewCleaner(cfg.Storage, slackWebhook, segmentCatalog)
 	cleaner.Start(10 * time.Minute) // Check disk usage every 10 minutes
 
 	// Start recorders for each enabled camera
 	var wg sync.WaitGroup
-	recorders := make([]*recorder.Recorder, 0)
+	cameras := supervisor.New(ctx, cfg, *configPath, segmentCatalog)
 
-	for _, cam := range cfg.Cameras {
-		if !cam.Enabled {
-			log.Printf("Camera %s is disabled, skipping", cam.Name)
-			continue
-		}
+	// Limit concurrent FFmpeg startups and stagger cameras so they don't all
+	// connect at once
+	recorder.SetMaxConcurrentStarts(cfg.System.MaxConcurrentStarts)
 
-		log.Printf("Starting recorder for camera: %s", cam.Name)
-		rec := recorder.New(cam, cfg.Storage)
-		recorders = append(recorders, rec)
+	// Start live streams only while someone is watching, if configured
+	recorder.SetLiveIdleTimeout(time.Duration(cfg.System.LiveIdleTimeout) * time.Second)
 
-		wg.Add(1)
-		go func(r *recorder.Recorder) {
-			defer wg.Done()
-			r.Start(ctx)
-		}(rec)
+	// Serve live view as Low-Latency HLS, if configured
+	recorder.SetLowLatencyLive(cfg.System.LowLatencyLive)
+
+	// Store motion and other camera events next to the recordings
+	eventStore := events.Open(cfg.Storage.BasePath)
+	recorder.SetEventStore(eventStore)
+
+	// Send events that need attention, such as loud noises and tampering
+	recorder.SetNotifier(func(message string) {
+		sendNotification(cfg, message)
+	})
+
+	// Send motion frames to the object detector, if configured
+	if cfg.Detector.Enabled {
+		recorder.SetObjectDetector(detect.New(cfg.Detector))
+		log.Printf("Object detector: %s", cfg.Detector.DetectorType())
 	}
 
+	stagger := time.Duration(cfg.System.StartupStagger) * time.Second
+	if cfg.System.StartupStagger == 0 {
+		stagger = 2 * time.Second
+	}
+	cameras.StartAll(stagger)
+
 	// Start health monitor if configured
 	if cfg.System.HealthCheckInterval > 0 {
-		go startHealthMonitor(ctx, cfg, recorders)
+		go startHealthMonitor(ctx, cfg, cameras, segmentCatalog)
 	}
 
 	// Start recovery manager if enabled
 	if cfg.Recovery.Enabled {
 		log.Println("Initializing camera recovery system...")
-		recoveryMgr, err := recovery.NewRecoveryManager(&cfg.Recovery, recorders, ctx)
+		recoveryMgr, err := recovery.NewRecoveryManager(&cfg.Recovery, cameras.Recorders(), ctx)
 		if err != nil {
 			log.Printf("WARNING: Failed to initialize recovery manager: %v", err)
 			log.Println("Continuing without automatic recovery...")
 		} else {
+			// Keep monitoring cameras added or removed at runtime
+			cameras.Watch(recoveryMgr)
+
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
@@ -118,9 +173,24 @@ func main() {
 		}
 	}
 
+	// Start RTSP re-streaming if enabled
+	var rtspServer *rtsp.Server
+	if cfg.RTSP.Enabled {
+		rtspServer = rtsp.NewServer(cfg.RTSP, cfg.WebUI.Authentication, cameras)
+		if err := rtspServer.Start(); err != nil {
+			log.Printf("WARNING: %v", err)
+			log.Println("Continuing without RTSP re-streaming...")
+			rtspServer = nil
+		}
+	}
+
 	// Start web UI if enabled
 	if cfg.WebUI.Enabled {
-		webServer := webui.NewServer(cfg, cfg.WebUI.Port)
+		webServer := webui.NewServer(cfg, cfg.WebUI.Port, segmentCatalog, cameras)
+		if rtspServer != nil {
+			webServer.SetRTSPServer(rtspServer)
+		}
+		webServer.SetEventStore(eventStore)
 		webServer.Start()
 		log.Printf("Web UI available at http://0.0.0.0:%d", cfg.WebUI.Port)
 	}
@@ -129,28 +199,21 @@ func main() {
 	sig := <-sigChan
 	log.Printf("Received signal %v, shutting down...", sig)
 
-	// Cancel context to stop all recorders
-	cancel()
-
-	// Stop all recorders gracefully
-	for _, rec := range recorders {
-		rec.Stop()
+	// Disconnect RTSP clients before their streams go away
+	if rtspServer != nil {
+		rtspServer.Stop()
 	}
 
-	// Wait for all goroutines to finish
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
+	// Cancel context to stop all recorders
+	cancel()
 
-	// Wait with timeout
-	select {
-	case <-done:
+	// Stop all recorders gracefully and wait with timeout
+	if cameras.StopAll(10 * time.Second) {
 		log.Println("All recorders stopped gracefully")
-	case <-time.After(10 * time.Second):
+	} else {
 		log.Println("Timeout waiting for recorders to stop")
 	}
+	wg.Wait()
 
 	log.Println("CoreNVR shutdown complete")
 }
@@ -178,7 +241,7 @@ func setupLogging(cfg config.SystemConfig) {
 }
 
 // startHealthMonitor runs periodic health checks
-func startHealthMonitor(ctx context.Context, cfg *config.Config, recorders []*recorder.Recorder) {
+func startHealthMonitor(ctx context.Context, cfg *config.Config, cameras *supervisor.Supervisor, segmentCatalog *catalog.Catalog) {
 	ticker := time.NewTicker(time.Duration(cfg.System.HealthCheckInterval) * time.Second)
 	defer ticker.Stop()
 
@@ -187,13 +250,13 @@ func startHealthMonitor(ctx context.Context, cfg *config.Config, recorders []*re
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			performHealthCheck(cfg, recorders)
+			performHealthCheck(cfg, cameras.Recorders(), segmentCatalog)
 		}
 	}
 }
 
 // performHealthCheck checks system health
-func performHealthCheck(cfg *config.Config, recorders []*recorder.Recorder) {
+func performHealthCheck(cfg *config.Config, recorders []*recorder.Recorder, segmentCatalog *catalog.Catalog) {
 	// Check disk space
 	_, err := os.Stat(cfg.Storage.BasePath)
 	if err != nil {
@@ -219,19 +282,34 @@ func performHealthCheck(cfg *config.Config, recorders []*recorder.Recorder) {
 	}
 
 	// Check if recordings are being created
-	for _, cam := range cfg.Cameras {
-		if !cam.Enabled {
+	for _, rec := range recorders {
+		expected, scheduledOnAt := rec.RecordingExpected()
+		if !expected || time.Since(rec.GetLastRecordingTime()) <= 10*time.Minute || time.Since(scheduledOnAt) <= 10*time.Minute {
 			continue
 		}
 
-		cameraPath := fmt.Sprintf("%s/%s/%s", cfg.Storage.BasePath, cam.Name, time.Now().Format("2006-01-02"))
-		if info, err := os.Stat(cameraPath); err != nil || time.Since(info.ModTime()) > 10*time.Minute {
-			log.Printf("WARNING: No recent recordings for camera %s", cam.Name)
-			sendNotification(cfg, fmt.Sprintf("No recent recordings for camera %s", cam.Name))
+		message := fmt.Sprintf("No recent recordings for camera %s", rec.GetCameraName())
+		if reason := rec.FailureReason(); reason != recorder.FailureNone {
+			message += fmt.Sprintf(" (%s: %s)", reason, reason.Description())
 		}
+
+		log.Printf("WARNING: %s", message)
+		sendNotification(cfg, message)
+	}
+
+	// Report interrupted segments that couldn't be repaired
+	repairs := segmentCatalog.RepairStats()
+	if repairs.Failed > reportedRepairFailures {
+		message := fmt.Sprintf("%d interrupted segments could not be repaired (%d repaired)", repairs.Failed, repairs.Repaired())
+		log.Printf("WARNING: %s", message)
+		sendNotification(cfg, message)
+		reportedRepairFailures = repairs.Failed
 	}
 }
 
+// reportedRepairFailures is the unrepairable segment count already reported
+var reportedRepairFailures int
+
 // sendNotification sends alerts if configured
 func sendNotification(cfg *config.Config, message string) {
 	if !cfg.Notifications.Enabled {
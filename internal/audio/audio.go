@@ -0,0 +1,140 @@
+// Package audio watches camera audio for loud noises, such as breaking
+// glass or shouting
+package audio
+
+import (
+	"math"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+)
+
+// SampleRate is the rate of the mono 16-bit samples the detector takes
+const SampleRate = 8000
+
+// WindowSamples is how many samples make up one loudness measurement, 100ms
+const WindowSamples = SampleRate / 10
+
+// Window is the length of one loudness measurement
+const Window = 100 * time.Millisecond
+
+const (
+	defaultThreshold = -20.0
+	defaultDuration  = 500 * time.Millisecond
+
+	// quietTime is how long it must stay quiet to end an event
+	quietTime = 2 * time.Second
+
+	// silence is the level reported for digital silence
+	silence = -96.0
+)
+
+// Change is a loud noise starting or ending
+type Change struct {
+	Started bool
+	Start   time.Time
+	End     time.Time // End of the last loud window, once ended
+	Peak    float64   // Loudest window so far, in dBFS
+}
+
+// Detector turns audio into loud noise events
+type Detector struct {
+	threshold float64 // dBFS
+	duration  time.Duration
+
+	loudSince time.Time // Start of the current run of loud windows, zero if quiet
+	runPeak   float64
+	active    bool
+	start     time.Time
+	lastLoud  time.Time // End of the latest loud window
+	peak      float64
+}
+
+// NewDetector creates a detector with a camera's audio settings
+func NewDetector(cfg config.AudioConfig) *Detector {
+	threshold := cfg.Threshold
+	if threshold == 0 {
+		threshold = defaultThreshold
+	}
+	duration := time.Duration(cfg.Duration * float64(time.Second))
+	if duration == 0 {
+		duration = defaultDuration
+	}
+	return &Detector{threshold: threshold, duration: duration}
+}
+
+// Threshold returns the level that counts as loud, in dBFS
+func (d *Detector) Threshold() float64 {
+	return d.threshold
+}
+
+// Feed measures one window of samples starting at at. It returns a change
+// when a noise has been loud for the configured duration, or when it has
+// been quiet for a while after one.
+func (d *Detector) Feed(samples []int16, at time.Time) (Change, bool) {
+	level := Level(samples)
+	end := at.Add(Window)
+
+	if level < d.threshold {
+		d.loudSince = time.Time{}
+		if d.active && end.Sub(d.lastLoud) >= quietTime {
+			d.active = false
+			return Change{Start: d.start, End: d.lastLoud, Peak: d.peak}, true
+		}
+		return Change{}, false
+	}
+
+	if d.loudSince.IsZero() {
+		d.loudSince = at
+		d.runPeak = level
+	}
+	d.runPeak = math.Max(d.runPeak, level)
+	d.lastLoud = end
+
+	if d.active {
+		d.peak = math.Max(d.peak, level)
+		return Change{}, false
+	}
+	if end.Sub(d.loudSince) < d.duration {
+		return Change{}, false
+	}
+
+	d.active = true
+	d.start = d.loudSince
+	d.peak = d.runPeak
+	return Change{Started: true, Start: d.start, Peak: d.peak}, true
+}
+
+// Active reports whether an event is in progress
+func (d *Detector) Active() bool {
+	return d.active
+}
+
+// Reset ends any event in progress, for when the audio stops
+func (d *Detector) Reset() (Change, bool) {
+	d.loudSince = time.Time{}
+	if !d.active {
+		return Change{}, false
+	}
+	d.active = false
+	return Change{Start: d.start, End: d.lastLoud, Peak: d.peak}, true
+}
+
+// Level returns the RMS loudness of samples in dBFS, from 0 for a full-scale
+// square wave down to -96 for silence
+func Level(samples []int16) float64 {
+	if len(samples) == 0 {
+		return silence
+	}
+
+	var sum float64
+	for _, s := range samples {
+		v := float64(s) / 32768
+		sum += v * v
+	}
+	rms := math.Sqrt(sum / float64(len(samples)))
+	if rms == 0 {
+		return silence
+	}
+	return math.Max(20*math.Log10(rms), silence)
+}
@@ -49,12 +49,18 @@ func NewSessionManager(username, passwordHash, secretKey string, timeoutMinutes
 
 // Authenticate checks if username and password are valid
 func (sm *SessionManager) Authenticate(username, password string) bool {
-	if username != sm.username {
+	return CheckPassword(sm.username, sm.passwordHash, username, password)
+}
+
+// CheckPassword checks a login against the configured username and bcrypt
+// password hash, for services that don't use sessions
+func CheckPassword(wantUsername, passwordHash, username, password string) bool {
+	if username != wantUsername {
 		return false
 	}
 
 	// Compare password with bcrypt hash
-	err := bcrypt.CompareHashAndPassword([]byte(sm.passwordHash), []byte(password))
+	err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
 	return err == nil
 }
 
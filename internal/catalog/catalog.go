@@ -0,0 +1,567 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// catalogFile is the per-camera index file, stored next to the recordings folder
+const catalogFile = "catalog.json"
+
+// Segment describes a single recording file
+type Segment struct {
+	Camera    string    `json:"camera"`
+	Date      string    `json:"date"`     // Date folder (YYYY-MM-DD)
+	Filename  string    `json:"filename"` // HH-MM-SS.ts or HH-MM-SS.mp4
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Size      int64     `json:"size"`
+	Codec     string    `json:"codec"`
+	Keyframes int       `json:"keyframes"`
+}
+
+// Duration returns how much video the segment holds
+func (s Segment) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// cameraIndex holds the segments known for one camera
+type cameraIndex struct {
+	segments []Segment // Finalized segments, sorted by start time
+	active   *Segment  // Segment FFmpeg is currently writing (not persisted)
+}
+
+// Catalog is a persistent index of recorded segments for all cameras
+type Catalog struct {
+	basePath string
+	logger   *log.Logger
+	cameras  map[string]*cameraIndex
+	openedAt time.Time   // Files last written before this were left by a previous run
+	repairs  RepairStats // Guarded by mu
+	mu       sync.RWMutex
+}
+
+// Open loads every camera catalog found under basePath
+func Open(basePath string) (*Catalog, error) {
+	c := &Catalog{
+		basePath: basePath,
+		logger:   log.New(os.Stdout, "[Catalog] ", log.LstdFlags),
+		cameras:  make(map[string]*cameraIndex),
+		openedAt: time.Now(),
+	}
+
+	entries, err := os.ReadDir(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading storage directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(basePath, entry.Name(), catalogFile)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			c.logger.Printf("Failed to read %s: %v", path, err)
+			continue
+		}
+
+		var segments []Segment
+		if err := json.Unmarshal(data, &segments); err != nil {
+			// A damaged catalog is rebuilt from disk by Sync
+			c.logger.Printf("Ignoring corrupt catalog %s: %v", path, err)
+			continue
+		}
+
+		sortSegments(segments)
+		c.cameras[entry.Name()] = &cameraIndex{segments: segments}
+	}
+
+	c.logger.Printf("Loaded catalogs for %d cameras", len(c.cameras))
+	return c, nil
+}
+
+// HasCamera reports whether a catalog exists for the camera
+func (c *Catalog) HasCamera(camera string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.cameras[camera]
+	return ok
+}
+
+// Cameras returns the names of all cataloged cameras
+func (c *Catalog) Cameras() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.cameras))
+	for name := range c.cameras {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dates returns the dates with recordings for a camera, oldest first
+func (c *Catalog) Dates(camera string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	idx, ok := c.cameras[camera]
+	if !ok {
+		return nil
+	}
+
+	var dates []string
+	for _, seg := range c.all(idx) {
+		if len(dates) == 0 || dates[len(dates)-1] != seg.Date {
+			dates = append(dates, seg.Date)
+		}
+	}
+	return dates
+}
+
+// Segments returns all segments recorded on a date, including the one
+// currently being written
+func (c *Catalog) Segments(camera, date string) []Segment {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	idx, ok := c.cameras[camera]
+	if !ok {
+		return nil
+	}
+
+	var result []Segment
+	for _, seg := range c.all(idx) {
+		if seg.Date == date {
+			result = append(result, seg)
+		}
+	}
+	return result
+}
+
+// Latest returns the most recent segment for a camera, including the one
+// currently being written
+func (c *Catalog) Latest(camera string) (Segment, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	idx, ok := c.cameras[camera]
+	if !ok {
+		return Segment{}, false
+	}
+
+	all := c.all(idx)
+	if len(all) == 0 {
+		return Segment{}, false
+	}
+	return all[len(all)-1], true
+}
+
+// Size returns the total bytes recorded for a camera, optionally limited to one date
+func (c *Catalog) Size(camera, date string) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	idx, ok := c.cameras[camera]
+	if !ok {
+		return 0
+	}
+
+	var size int64
+	for _, seg := range c.all(idx) {
+		if date == "" || seg.Date == date {
+			size += seg.Size
+		}
+	}
+	return size
+}
+
+// Contains reports whether a file is already cataloged as a finalized segment
+func (c *Catalog) Contains(camera, date, filename string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	idx, ok := c.cameras[camera]
+	if !ok {
+		return false
+	}
+	return idx.find(date, filename) >= 0
+}
+
+// Add records a finalized segment, replacing any previous entry for the same file
+func (c *Catalog) Add(seg Segment) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.add(seg)
+	return c.save(seg.Camera)
+}
+
+// AddFile probes a finished recording file and adds it to the catalog
+func (c *Catalog) AddFile(camera, date, filename string) (Segment, error) {
+	seg, err := c.probeSegment(camera, date, filename)
+	if err != nil {
+		return Segment{}, err
+	}
+	return seg, c.Add(seg)
+}
+
+// SetActive marks the segment FFmpeg is currently writing. Its end time and
+// size are taken from the file whenever the catalog is queried.
+func (c *Catalog) SetActive(camera, date, filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	start, err := parseStart(date, filename)
+	if err != nil {
+		return
+	}
+
+	c.index(camera).active = &Segment{
+		Camera:   camera,
+		Date:     date,
+		Filename: filename,
+		Start:    start,
+		End:      start,
+	}
+}
+
+// ClearActive forgets the segment being written and returns it, if any
+func (c *Catalog) ClearActive(camera string) (Segment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, ok := c.cameras[camera]
+	if !ok || idx.active == nil {
+		return Segment{}, false
+	}
+
+	seg := *idx.active
+	idx.active = nil
+	return seg, true
+}
+
+// RemoveDate drops all segments of a date from the catalog
+func (c *Catalog) RemoveDate(camera, date string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, ok := c.cameras[camera]
+	if !ok {
+		return nil
+	}
+
+	kept := idx.segments[:0]
+	for _, seg := range idx.segments {
+		if seg.Date != date {
+			kept = append(kept, seg)
+		}
+	}
+	idx.segments = kept
+
+	return c.save(camera)
+}
+
+// Sync brings a camera's catalog up to date with the files on disk. Cameras
+// without a catalog are rebuilt completely; otherwise only the most recent
+// date folders are scanned for segments that were never finalized. The newest
+// of those is repaired, since a crash can leave it truncated.
+func (c *Catalog) Sync(camera string) error {
+	if !c.HasCamera(camera) {
+		return c.Rebuild(camera)
+	}
+
+	fromDate := ""
+	if latest, ok := c.Latest(camera); ok {
+		fromDate = latest.Date
+	}
+
+	added, err := c.scan(camera, fromDate, true)
+	if added > 0 {
+		c.logger.Printf("Camera %s: cataloged %d segments found on disk", camera, added)
+	}
+	return err
+}
+
+// Rebuild discards a camera's catalog and recreates it from the files on
+// disk. Files are only probed, not repaired.
+func (c *Catalog) Rebuild(camera string) error {
+	c.logger.Printf("Rebuilding catalog for camera %s...", camera)
+
+	c.mu.Lock()
+	idx := c.index(camera)
+	idx.segments = nil
+	c.mu.Unlock()
+
+	added, err := c.scan(camera, "", false)
+	if err != nil {
+		return err
+	}
+
+	c.logger.Printf("Camera %s: catalog rebuilt with %d segments", camera, added)
+	return nil
+}
+
+// scan adds uncataloged files from date folders on or after fromDate. With
+// repair set, the newest file left by a previous run is repaired first.
+func (c *Catalog) scan(camera, fromDate string, repair bool) (int, error) {
+	recordingsDir := filepath.Join(c.basePath, camera, "recordings")
+
+	dateDirs, err := os.ReadDir(recordingsDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading recordings directory: %w", err)
+	}
+
+	c.mu.RLock()
+	var active Segment
+	if idx, ok := c.cameras[camera]; ok && idx.active != nil {
+		active = *idx.active
+	}
+	c.mu.RUnlock()
+
+	type uncataloged struct {
+		date, name string
+	}
+	var pending []uncataloged
+	repairIndex := -1
+	for _, dateDir := range dateDirs {
+		date := dateDir.Name()
+		if !dateDir.IsDir() || !IsDateDir(date) || date < fromDate {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(recordingsDir, date))
+		if err != nil {
+			c.logger.Printf("Failed to read %s: %v", date, err)
+			continue
+		}
+
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || !IsSegmentFile(name) {
+				continue
+			}
+			if c.Contains(camera, date, name) || (active.Date == date && active.Filename == name) {
+				continue
+			}
+
+			// Only the newest file from a previous run can have been open
+			// when it stopped. Newer files may still be open in FFmpeg, so
+			// leave those alone.
+			if info, err := file.Info(); err == nil && repair && info.ModTime().Before(c.openedAt) {
+				repairIndex = len(pending)
+			}
+			pending = append(pending, uncataloged{date, name})
+		}
+	}
+
+	added := 0
+	for i, file := range pending {
+		if i == repairIndex {
+			outcome, _ := c.repair(camera, file.date, file.name)
+			if outcome == RepairRemoved || outcome == RepairFailed {
+				continue
+			}
+		}
+
+		seg, err := c.probeSegment(camera, file.date, file.name)
+		if err != nil {
+			c.logger.Printf("Skipping %s/%s: %v", file.date, file.name, err)
+			continue
+		}
+
+		c.mu.Lock()
+		c.add(seg)
+		c.mu.Unlock()
+		added++
+	}
+
+	// Save once at the end rather than rewriting the catalog per file
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.cameras[camera]; !ok {
+		return added, nil
+	}
+	return added, c.save(camera)
+}
+
+// probeSegment builds a Segment from a recording file on disk
+func (c *Catalog) probeSegment(camera, date, filename string) (Segment, error) {
+	start, err := parseStart(date, filename)
+	if err != nil {
+		return Segment{}, err
+	}
+
+	path := c.SegmentPath(camera, date, filename)
+	info, err := os.Stat(path)
+	if err != nil {
+		return Segment{}, fmt.Errorf("stat segment: %w", err)
+	}
+
+	seg := Segment{
+		Camera:   camera,
+		Date:     date,
+		Filename: filename,
+		Start:    start,
+		Size:     info.Size(),
+	}
+
+	probe, err := Probe(path)
+	if err != nil || probe.Duration <= 0 {
+		// Unreadable file - estimate from the last write time
+		if err != nil {
+			c.logger.Printf("Probe failed for %s, using file times: %v", path, err)
+		}
+		seg.End = info.ModTime()
+		if seg.End.Before(start) {
+			seg.End = start
+		}
+		return seg, nil
+	}
+
+	seg.End = start.Add(probe.Duration)
+	seg.Codec = probe.Codec
+	seg.Keyframes = probe.Keyframes
+	return seg, nil
+}
+
+// SegmentPath returns the path of a recording file
+func (c *Catalog) SegmentPath(camera, date, filename string) string {
+	return filepath.Join(c.basePath, camera, "recordings", date, filename)
+}
+
+// save writes a camera's catalog atomically. Caller must hold c.mu.
+func (c *Catalog) save(camera string) error {
+	idx := c.cameras[camera]
+
+	segments := idx.segments
+	if segments == nil {
+		segments = []Segment{}
+	}
+
+	data, err := json.Marshal(segments)
+	if err != nil {
+		return fmt.Errorf("encoding catalog: %w", err)
+	}
+
+	dir := filepath.Join(c.basePath, camera)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating camera directory: %w", err)
+	}
+
+	path := filepath.Join(dir, catalogFile)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("writing catalog: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("replacing catalog: %w", err)
+	}
+
+	return nil
+}
+
+// add inserts a segment in memory without saving. Caller must hold c.mu.
+func (c *Catalog) add(seg Segment) {
+	idx := c.index(seg.Camera)
+	if i := idx.find(seg.Date, seg.Filename); i >= 0 {
+		idx.segments[i] = seg
+	} else {
+		idx.segments = append(idx.segments, seg)
+		sortSegments(idx.segments)
+	}
+
+	// The active segment is finalized once it is added
+	if idx.active != nil && idx.active.Date == seg.Date && idx.active.Filename == seg.Filename {
+		idx.active = nil
+	}
+}
+
+// index returns the index for a camera, creating it if needed. Caller must hold c.mu.
+func (c *Catalog) index(camera string) *cameraIndex {
+	idx, ok := c.cameras[camera]
+	if !ok {
+		idx = &cameraIndex{}
+		c.cameras[camera] = idx
+	}
+	return idx
+}
+
+// find returns the position of a finalized segment, or -1
+func (idx *cameraIndex) find(date, filename string) int {
+	for i := len(idx.segments) - 1; i >= 0; i-- {
+		if idx.segments[i].Date == date && idx.segments[i].Filename == filename {
+			return i
+		}
+	}
+	return -1
+}
+
+// all returns finalized segments followed by the active one, refreshed from disk
+func (c *Catalog) all(idx *cameraIndex) []Segment {
+	if idx.active == nil {
+		return idx.segments
+	}
+
+	active := *idx.active
+	if info, err := os.Stat(c.SegmentPath(active.Camera, active.Date, active.Filename)); err == nil {
+		active.Size = info.Size()
+		if info.ModTime().After(active.Start) {
+			active.End = info.ModTime()
+		}
+	}
+
+	all := make([]Segment, 0, len(idx.segments)+1)
+	all = append(all, idx.segments...)
+	return append(all, active)
+}
+
+// parseStart derives a segment's start time from its date folder and HH-MM-SS filename
+func parseStart(date, filename string) (time.Time, error) {
+	name := strings.TrimSuffix(filename, filepath.Ext(filename))
+	start, err := time.ParseInLocation("2006-01-02 15-04-05", date+" "+name, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected segment name %s/%s", date, filename)
+	}
+	return start, nil
+}
+
+// IsSegmentFile reports whether a file name is a recording segment, either
+// MPEG-TS (.ts) or fragmented MP4 (.mp4)
+func IsSegmentFile(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".ts" || ext == ".mp4"
+}
+
+// IsDateDir reports whether a folder name is a YYYY-MM-DD date folder
+func IsDateDir(name string) bool {
+	if len(name) != 10 || name[4] != '-' || name[7] != '-' {
+		return false
+	}
+	_, err := time.Parse("2006-01-02", name)
+	return err == nil
+}
+
+// sortSegments orders segments by start time
+func sortSegments(segments []Segment) {
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Start.Before(segments[j].Start)
+	})
+}
@@ -0,0 +1,69 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProbeResult holds what FFprobe reports about a recording file
+type ProbeResult struct {
+	Codec     string
+	Keyframes int
+	Duration  time.Duration
+}
+
+// ffprobeOutput mirrors the parts of FFprobe's JSON output we use
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecName string `json:"codec_name"`
+	} `json:"streams"`
+	Packets []struct {
+		Flags string `json:"flags"`
+	} `json:"packets"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// Probe reads a recording file with FFprobe to get its video codec,
+// keyframe count and duration
+func Probe(path string) (ProbeResult, error) {
+	cmd := exec.Command("ffprobe",
+		"-v", "quiet",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=codec_name:packet=flags:format=duration",
+		"-of", "json",
+		path,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return ProbeResult{}, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var parsed ffprobeOutput
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		return ProbeResult{}, fmt.Errorf("parsing ffprobe output: %w", err)
+	}
+
+	result := ProbeResult{}
+	if len(parsed.Streams) > 0 {
+		result.Codec = parsed.Streams[0].CodecName
+	}
+
+	for _, packet := range parsed.Packets {
+		if strings.Contains(packet.Flags, "K") {
+			result.Keyframes++
+		}
+	}
+
+	if seconds, err := strconv.ParseFloat(parsed.Format.Duration, 64); err == nil {
+		result.Duration = time.Duration(seconds * float64(time.Second))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,187 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// tsPacketSize is the size of an MPEG-TS packet. A segment cut off mid-write
+// ends with a partial packet.
+const tsPacketSize = 188
+
+// brokenSuffix is appended to segments that couldn't be repaired, which keeps
+// them out of the catalog while leaving them on disk for inspection
+const brokenSuffix = ".broken"
+
+// FragmentedMP4Flags are the -movflags that make FFmpeg write fragmented MP4
+// with one fragment per keyframe, suitable for HLS byte-range playback
+const FragmentedMP4Flags = "+frag_keyframe+empty_moov+default_base_moof"
+
+// RepairOutcome describes what repairing a segment did
+type RepairOutcome string
+
+const (
+	RepairOK      RepairOutcome = "ok"      // File was intact
+	RepairTrimmed RepairOutcome = "trimmed" // Partial trailing packet removed
+	RepairRemuxed RepairOutcome = "remuxed" // Rewritten from its readable packets
+	RepairRemoved RepairOutcome = "removed" // Empty file deleted
+	RepairFailed  RepairOutcome = "failed"  // Unreadable, renamed to .broken
+)
+
+// RepairStats counts segment repairs since startup
+type RepairStats struct {
+	Checked int `json:"checked"`
+	Trimmed int `json:"trimmed"`
+	Remuxed int `json:"remuxed"`
+	Removed int `json:"removed"`
+	Failed  int `json:"failed"`
+}
+
+// Repaired returns how many segments were fixed
+func (s RepairStats) Repaired() int {
+	return s.Trimmed + s.Remuxed
+}
+
+// RepairFile checks a segment FFmpeg didn't finish cleanly, fixes it if it's
+// truncated or unreadable, and adds it to the catalog with its true duration.
+// Empty and unrepairable files are not cataloged.
+func (c *Catalog) RepairFile(camera, date, filename string) (Segment, RepairOutcome, error) {
+	outcome, err := c.repair(camera, date, filename)
+	if outcome == RepairRemoved || outcome == RepairFailed {
+		return Segment{}, outcome, err
+	}
+
+	seg, err := c.AddFile(camera, date, filename)
+	return seg, outcome, err
+}
+
+// RepairStats returns the segment repair counters
+func (c *Catalog) RepairStats() RepairStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.repairs
+}
+
+// repair fixes an interrupted segment in place and logs the outcome
+func (c *Catalog) repair(camera, date, filename string) (RepairOutcome, error) {
+	path := c.SegmentPath(camera, date, filename)
+	outcome, err := repairSegment(path)
+
+	c.mu.Lock()
+	c.repairs.Checked++
+	switch outcome {
+	case RepairTrimmed:
+		c.repairs.Trimmed++
+	case RepairRemuxed:
+		c.repairs.Remuxed++
+	case RepairRemoved:
+		c.repairs.Removed++
+	case RepairFailed:
+		c.repairs.Failed++
+	}
+	c.mu.Unlock()
+
+	switch outcome {
+	case RepairOK:
+	case RepairFailed:
+		c.logger.Printf("❌ Camera %s: segment %s/%s is unrepairable, kept as %s: %v",
+			camera, date, filename, filename+brokenSuffix, err)
+	default:
+		c.logger.Printf("🔧 Camera %s: segment %s/%s %s", camera, date, filename, outcome)
+	}
+
+	return outcome, err
+}
+
+// repairSegment makes an interrupted segment file playable
+func repairSegment(path string) (RepairOutcome, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return RepairFailed, fmt.Errorf("stat segment: %w", err)
+	}
+
+	if info.Size() == 0 {
+		if err := os.Remove(path); err != nil {
+			return RepairFailed, fmt.Errorf("removing empty segment: %w", err)
+		}
+		return RepairRemoved, nil
+	}
+
+	outcome := RepairOK
+	isTS := strings.EqualFold(filepath.Ext(path), ".ts")
+
+	// Drop a trailing partial packet left by an interrupted write
+	if isTS && info.Size()%tsPacketSize != 0 {
+		if err := os.Truncate(path, info.Size()-info.Size()%tsPacketSize); err != nil {
+			return RepairFailed, fmt.Errorf("trimming segment: %w", err)
+		}
+		outcome = RepairTrimmed
+	}
+
+	probe, err := Probe(path)
+	if err == nil && probe.Duration > 0 {
+		return outcome, nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		// Without FFprobe the file can't be checked; don't call it broken
+		return outcome, nil
+	}
+
+	// Rewrite whatever FFmpeg can still read into a fresh file
+	if err := remux(path); err != nil {
+		if renameErr := os.Rename(path, path+brokenSuffix); renameErr != nil {
+			return RepairFailed, fmt.Errorf("%v (renaming: %v)", err, renameErr)
+		}
+		return RepairFailed, err
+	}
+
+	return RepairRemuxed, nil
+}
+
+// remux copies a segment's readable packets into a new file and replaces the
+// original with it
+func remux(path string) error {
+	// The temporary name must not look like a segment to catalog scans
+	tmpPath := path + ".repair"
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-y",
+		"-err_detect", "ignore_err",
+		"-fflags", "+genpts+discardcorrupt",
+		"-i", path,
+		"-map", "0",
+		"-c", "copy",
+	}
+	if strings.EqualFold(filepath.Ext(path), ".mp4") {
+		// Keep the file fragmented so it can still be served as HLS fMP4
+		args = append(args, "-f", "mp4", "-movflags", FragmentedMP4Flags)
+	} else {
+		args = append(args, "-f", "mpegts")
+	}
+	args = append(args, tmpPath)
+
+	cmd := exec.Command("ffmpeg", args...)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("remux failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	if probe, err := Probe(tmpPath); err != nil || probe.Duration <= 0 {
+		os.Remove(tmpPath)
+		return fmt.Errorf("remuxed segment is still unreadable")
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("replacing segment: %w", err)
+	}
+
+	return nil
+}
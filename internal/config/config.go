@@ -1,8 +1,11 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"strings"
+
 	"gopkg.in/yaml.v3"
 )
 
@@ -14,6 +17,8 @@ type Config struct {
 	WebUI         WebUIConfig          `yaml:"webui"`
 	Notifications NotificationsConfig  `yaml:"notifications"`
 	Recovery      RecoveryConfig       `yaml:"recovery"`
+	RTSP          RTSPConfig           `yaml:"rtsp"`
+	Detector      DetectorConfig       `yaml:"detector"`
 }
 
 // StorageConfig defines storage settings
@@ -25,11 +30,232 @@ type StorageConfig struct {
 
 // CameraConfig defines camera settings
 type CameraConfig struct {
-	Name       string `yaml:"name"`
-	URL        string `yaml:"url"`
-	Enabled    bool   `yaml:"enabled"`
-	RetryDelay int    `yaml:"retry_delay"`  // seconds
-	MaxRetries int    `yaml:"max_retries"`  // -1 for infinite
+	Name            string `yaml:"name" json:"name"`
+	URL             string `yaml:"url" json:"url"`
+	RecordURL       string `yaml:"record_url,omitempty" json:"record_url,omitempty"`   // optional main stream for recording
+	LiveURL         string `yaml:"live_url,omitempty" json:"live_url,omitempty"`       // optional stream for live view
+	PreviewURL      string `yaml:"preview_url,omitempty" json:"preview_url,omitempty"` // optional low-res stream for grid tiles
+	Enabled         bool   `yaml:"enabled" json:"enabled"`
+	RetryDelay      int    `yaml:"retry_delay" json:"retry_delay"`                                 // seconds, initial backoff (default: 5)
+	RetryMaxDelay   int    `yaml:"retry_max_delay,omitempty" json:"retry_max_delay,omitempty"`     // seconds, backoff cap (default: 300)
+	RetryResetAfter int    `yaml:"retry_reset_after,omitempty" json:"retry_reset_after,omitempty"` // seconds of stable running that reset backoff (default: 120)
+	MaxRetries      int    `yaml:"max_retries" json:"max_retries"`                                 // -1 for infinite
+	SingleIngest    bool   `yaml:"single_ingest,omitempty" json:"single_ingest,omitempty"`         // one connection feeds recording and live view
+	Container       string `yaml:"container,omitempty" json:"container,omitempty"`                 // recording format: mpegts (default) or fmp4
+
+	// Optional weekly recording schedule; live view stays available outside it
+	Schedule *ScheduleConfig `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// Seconds between stored snapshots (0 = off)
+	SnapshotInterval int `yaml:"snapshot_interval,omitempty" json:"snapshot_interval,omitempty"`
+
+	// Optional motion detection on the preview stream, or the live stream
+	Motion *MotionConfig `yaml:"motion,omitempty" json:"motion,omitempty"`
+
+	// Optional tamper detection: black, covered or frozen image, or no video
+	Tamper *TamperConfig `yaml:"tamper,omitempty" json:"tamper,omitempty"`
+
+	// Optional audio level monitoring for loud noises such as breaking glass
+	Audio *AudioConfig `yaml:"audio,omitempty" json:"audio,omitempty"`
+
+	// Optional object detection on motion, by the detector service
+	Objects *ObjectsConfig `yaml:"objects,omitempty" json:"objects,omitempty"`
+
+	// Event recording: in events mode only clips around triggers are saved
+	RecordMode     string  `yaml:"record_mode,omitempty" json:"record_mode,omitempty"`         // continuous (default) or events
+	PreRoll        int     `yaml:"pre_roll,omitempty" json:"pre_roll,omitempty"`               // seconds kept before a trigger (default: 5)
+	PostRoll       int     `yaml:"post_roll,omitempty" json:"post_roll,omitempty"`             // seconds kept after the last trigger (default: 10)
+	SceneThreshold float64 `yaml:"scene_threshold,omitempty" json:"scene_threshold,omitempty"` // FFmpeg scene score that triggers, 0-1 (default: 0.3, -1 = off)
+}
+
+// MotionConfig defines motion detection for a camera
+type MotionConfig struct {
+	Enabled     bool         `yaml:"enabled" json:"enabled"`
+	Sensitivity int          `yaml:"sensitivity,omitempty" json:"sensitivity,omitempty"` // 1-100 (default: 50)
+	Cooldown    int          `yaml:"cooldown,omitempty" json:"cooldown,omitempty"`       // seconds without motion that end an event (default: 10)
+	Zones       []MotionZone `yaml:"zones,omitempty" json:"zones,omitempty"`             // areas to watch (default: the whole image)
+}
+
+// MotionZone is a rectangle of the image, in fractions of its width and
+// height from the top left corner
+type MotionZone struct {
+	Name   string  `yaml:"name" json:"name"`
+	X      float64 `yaml:"x" json:"x"`
+	Y      float64 `yaml:"y" json:"y"`
+	Width  float64 `yaml:"width" json:"width"`
+	Height float64 `yaml:"height" json:"height"`
+}
+
+// Validate checks motion detection settings
+func (m MotionConfig) Validate() error {
+	if m.Sensitivity < 0 || m.Sensitivity > 100 {
+		return fmt.Errorf("motion sensitivity must be 1-100")
+	}
+	if m.Cooldown < 0 {
+		return fmt.Errorf("motion cooldown can't be negative")
+	}
+	// Allow for rounding in fractions such as 0.7 + 0.3
+	for i, zone := range m.Zones {
+		if zone.X < 0 || zone.Y < 0 || zone.Width <= 0 || zone.Height <= 0 ||
+			zone.X+zone.Width > 1.000001 || zone.Y+zone.Height > 1.000001 {
+			return fmt.Errorf("motion zone %d (%s) must lie within the image (0-1)", i+1, zone.Name)
+		}
+	}
+	return nil
+}
+
+// TamperConfig defines tamper and video loss detection for a camera
+type TamperConfig struct {
+	Enabled  bool `yaml:"enabled" json:"enabled"`
+	HoldTime int  `yaml:"hold_time,omitempty" json:"hold_time,omitempty"` // seconds a condition must last to alert (default: 30)
+}
+
+// AudioConfig defines audio level monitoring for a camera
+type AudioConfig struct {
+	Enabled   bool    `yaml:"enabled" json:"enabled"`
+	Threshold float64 `yaml:"threshold,omitempty" json:"threshold,omitempty"` // dBFS that counts as loud, -90 to 0 (default: -20)
+	Duration  float64 `yaml:"duration,omitempty" json:"duration,omitempty"`   // seconds it must stay loud to raise an event (default: 0.5)
+}
+
+// Validate checks audio monitoring settings
+func (a AudioConfig) Validate() error {
+	if a.Threshold < -90 || a.Threshold > 0 {
+		return fmt.Errorf("audio threshold must be -90 to 0 dBFS")
+	}
+	if a.Duration < 0 {
+		return fmt.Errorf("audio duration can't be negative")
+	}
+	return nil
+}
+
+// ObjectsConfig defines object detection for a camera. Frames are sent to
+// the detector while motion is seen.
+type ObjectsConfig struct {
+	Enabled       bool     `yaml:"enabled" json:"enabled"`
+	Labels        []string `yaml:"labels,omitempty" json:"labels,omitempty"`                 // labels that alert, such as person or car (default: any)
+	MinConfidence float64  `yaml:"min_confidence,omitempty" json:"min_confidence,omitempty"` // 0-1, below which detections are ignored (default: 0.5)
+}
+
+// Object detector types
+const (
+	DetectorDeepStack = "deepstack" // DeepStack or CodeProject.AI HTTP API
+	DetectorMock      = "mock"      // Reports fixed labels, for testing
+)
+
+// Recording containers
+const (
+	ContainerMPEGTS = "mpegts"
+	ContainerFMP4   = "fmp4"
+)
+
+// RecordContainer returns the recording container, defaulting to MPEG-TS
+func (c CameraConfig) RecordContainer() string {
+	if c.Container == "" {
+		return ContainerMPEGTS
+	}
+	return c.Container
+}
+
+// Recording modes
+const (
+	RecordContinuous = "continuous"
+	RecordEvents     = "events"
+)
+
+// RecordingMode returns the recording mode, defaulting to continuous
+func (c CameraConfig) RecordingMode() string {
+	if c.RecordMode == "" {
+		return RecordContinuous
+	}
+	return c.RecordMode
+}
+
+// SegmentExtension returns the file extension of recording segments
+func (c CameraConfig) SegmentExtension() string {
+	if c.RecordContainer() == ContainerFMP4 {
+		return ".mp4"
+	}
+	return ".ts"
+}
+
+// Clone returns a copy of the camera settings that shares nothing with c,
+// so it can be changed without affecting c
+func (c CameraConfig) Clone() CameraConfig {
+	if c.Schedule != nil {
+		schedule := *c.Schedule
+		schedule.Ranges = make([]ScheduleRange, len(c.Schedule.Ranges))
+		for i, r := range c.Schedule.Ranges {
+			r.Days = append([]string(nil), r.Days...)
+			schedule.Ranges[i] = r
+		}
+		c.Schedule = &schedule
+	}
+	if c.Motion != nil {
+		motion := *c.Motion
+		motion.Zones = append([]MotionZone(nil), c.Motion.Zones...)
+		c.Motion = &motion
+	}
+	if c.Tamper != nil {
+		tamper := *c.Tamper
+		c.Tamper = &tamper
+	}
+	if c.Audio != nil {
+		audio := *c.Audio
+		c.Audio = &audio
+	}
+	if c.Objects != nil {
+		objects := *c.Objects
+		objects.Labels = append([]string(nil), c.Objects.Labels...)
+		c.Objects = &objects
+	}
+	return c
+}
+
+// RecordStream returns the URL used for recording
+func (c CameraConfig) RecordStream() string {
+	if c.RecordURL != "" {
+		return c.RecordURL
+	}
+	return c.URL
+}
+
+// LiveStream returns the URL used for live view. In single-ingest mode live
+// view shares the recording connection.
+func (c CameraConfig) LiveStream() string {
+	if c.SingleIngest {
+		return c.RecordStream()
+	}
+	if c.LiveURL != "" {
+		return c.LiveURL
+	}
+	return c.URL
+}
+
+// StreamSources reports which config field feeds each view, so the API can
+// describe streams without exposing their credentials
+func (c CameraConfig) StreamSources() map[string]string {
+	record := "url"
+	if c.RecordURL != "" {
+		record = "record_url"
+	}
+
+	live := "url"
+	if c.SingleIngest {
+		live = record
+	} else if c.LiveURL != "" {
+		live = "live_url"
+	}
+
+	grid := live
+	if c.PreviewURL != "" {
+		grid = "preview_url"
+	}
+
+	return map[string]string{
+		"record": record,
+		"live":   live,
+		"grid":   grid,
+	}
 }
 
 // SystemConfig defines system settings
@@ -37,6 +263,10 @@ type SystemConfig struct {
 	LogLevel            string `yaml:"log_level"`
 	LogFile             string `yaml:"log_file"`
 	HealthCheckInterval int    `yaml:"health_check_interval"`
+	MaxConcurrentStarts int    `yaml:"max_concurrent_starts"` // FFmpeg processes connecting at once (default: 2)
+	StartupStagger      int    `yaml:"startup_stagger"`       // seconds between starting cameras (default: 2)
+	LiveIdleTimeout     int    `yaml:"live_idle_timeout"`     // seconds without viewers before live streams stop (0 = always on)
+	LowLatencyLive      bool   `yaml:"low_latency_live"`      // serve live view as Low-Latency HLS (~1s delay)
 }
 
 // WebUIConfig defines web interface settings
@@ -44,6 +274,52 @@ type WebUIConfig struct {
 	Enabled        bool       `yaml:"enabled"`
 	Port           int        `yaml:"port"`
 	Authentication AuthConfig `yaml:"authentication"`
+
+	// Optional WebRTC live view, falling back to HLS
+	WebRTC WebRTCConfig `yaml:"webrtc"`
+
+	// Motion JPEG stream for clients that can't play HLS
+	MJPEG MJPEGConfig `yaml:"mjpeg"`
+}
+
+// WebRTCConfig defines WebRTC live view settings
+type WebRTCConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	UDPPortMin int  `yaml:"udp_port_min"` // media port range (default: any free port)
+	UDPPortMax int  `yaml:"udp_port_max"`
+}
+
+// MJPEGConfig defines the /mjpeg/{camera} stream settings
+type MJPEGConfig struct {
+	FPS    int `yaml:"fps"`    // frames per second (default: 2)
+	Width  int `yaml:"width"`  // default: 640, -1 = camera resolution
+	Height int `yaml:"height"` // default: keep aspect ratio
+}
+
+// RTSPConfig defines the built-in RTSP re-streaming server
+type RTSPConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Port    int  `yaml:"port"`     // default: 8554
+	UDPPort int  `yaml:"udp_port"` // even RTP port, RTCP uses the next one (default: 8000, -1 = TCP only)
+}
+
+// DetectorConfig defines the object detection service that cameras send
+// motion frames to
+type DetectorConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	Type       string   `yaml:"type"`        // deepstack (default) or mock
+	URL        string   `yaml:"url"`         // detection endpoint, such as http://host:32168/v1/vision/detection
+	APIKey     string   `yaml:"api_key"`     // optional
+	Timeout    int      `yaml:"timeout"`     // seconds per image (default: 10)
+	MockLabels []string `yaml:"mock_labels"` // labels the mock detector reports (default: person)
+}
+
+// DetectorType returns the object detector type, deepstack by default
+func (d DetectorConfig) DetectorType() string {
+	if d.Type == "" {
+		return DetectorDeepStack
+	}
+	return d.Type
 }
 
 // AuthConfig defines authentication settings
@@ -119,11 +395,22 @@ func (c *Config) Validate() error {
 	}
 
 	enabledCameras := 0
+	names := make(map[string]bool)
 	for _, cam := range c.Cameras {
+		// The name is used as the camera's storage folder, even while the
+		// camera is disabled
+		if err := cam.ValidateName(); err != nil {
+			return err
+		}
+		if names[cam.Name] {
+			return fmt.Errorf("camera %s: duplicate name", cam.Name)
+		}
+		names[cam.Name] = true
+
 		if cam.Enabled {
 			enabledCameras++
-			if cam.URL == "" {
-				return fmt.Errorf("camera %s: URL is required", cam.Name)
+			if err := cam.Validate(); err != nil {
+				return err
 			}
 		}
 	}
@@ -132,5 +419,167 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("at least one camera must be enabled")
 	}
 
+	if rtc := c.WebUI.WebRTC; rtc.UDPPortMin != 0 || rtc.UDPPortMax != 0 {
+		if rtc.UDPPortMin < 1 || rtc.UDPPortMax > 65535 || rtc.UDPPortMin > rtc.UDPPortMax {
+			return fmt.Errorf("webui.webrtc: invalid UDP port range %d-%d", rtc.UDPPortMin, rtc.UDPPortMax)
+		}
+	}
+
+	if m := c.WebUI.MJPEG; m.FPS < 0 || m.FPS > 30 || m.Width < -1 || m.Height < 0 {
+		return fmt.Errorf("webui.mjpeg: fps must be 0-30, and width and height positive")
+	}
+
+	if c.RTSP.Port < 0 || c.RTSP.Port > 65535 {
+		return fmt.Errorf("rtsp: invalid port %d", c.RTSP.Port)
+	}
+	if p := c.RTSP.UDPPort; p != 0 && p != -1 && (p < 1 || p > 65534 || p%2 != 0) {
+		return fmt.Errorf("rtsp: udp_port must be an even port below 65535, or -1 for TCP only")
+	}
+
+	if d := c.Detector; d.Enabled {
+		switch d.DetectorType() {
+		case DetectorDeepStack:
+			if d.URL == "" {
+				return fmt.Errorf("detector: url is required")
+			}
+		case DetectorMock:
+		default:
+			return fmt.Errorf("detector: type must be %s or %s", DetectorDeepStack, DetectorMock)
+		}
+		if d.Timeout < 0 {
+			return fmt.Errorf("detector: timeout can't be negative")
+		}
+	}
+
+	return nil
+}
+
+// ValidateName checks that a camera's name is usable as its storage folder
+func (c CameraConfig) ValidateName() error {
+	if c.Name == "" || c.Name == "." || c.Name == ".." || strings.ContainsAny(c.Name, `/\`) {
+		return fmt.Errorf("camera %q: invalid name", c.Name)
+	}
+	return nil
+}
+
+// Validate checks if a camera's settings are valid
+func (c CameraConfig) Validate() error {
+	if err := c.ValidateName(); err != nil {
+		return err
+	}
+	if c.RecordStream() == "" {
+		return fmt.Errorf("camera %s: url or record_url is required", c.Name)
+	}
+	if c.LiveStream() == "" {
+		return fmt.Errorf("camera %s: url or live_url is required", c.Name)
+	}
+	if container := c.RecordContainer(); container != ContainerMPEGTS && container != ContainerFMP4 {
+		return fmt.Errorf("camera %s: container must be %s or %s", c.Name, ContainerMPEGTS, ContainerFMP4)
+	}
+	if c.Schedule != nil {
+		if err := c.Schedule.Validate(); err != nil {
+			return fmt.Errorf("camera %s: %w", c.Name, err)
+		}
+	}
+	if c.SnapshotInterval < 0 {
+		return fmt.Errorf("camera %s: snapshot_interval can't be negative", c.Name)
+	}
+	if c.Motion != nil {
+		if err := c.Motion.Validate(); err != nil {
+			return fmt.Errorf("camera %s: %w", c.Name, err)
+		}
+	}
+	if c.Tamper != nil && c.Tamper.HoldTime < 0 {
+		return fmt.Errorf("camera %s: tamper hold_time can't be negative", c.Name)
+	}
+	if c.Audio != nil {
+		if err := c.Audio.Validate(); err != nil {
+			return fmt.Errorf("camera %s: %w", c.Name, err)
+		}
+	}
+	if c.Objects != nil && c.Objects.Enabled {
+		if c.Motion == nil || !c.Motion.Enabled {
+			return fmt.Errorf("camera %s: objects needs motion detection enabled", c.Name)
+		}
+		if c.Objects.MinConfidence < 0 || c.Objects.MinConfidence > 1 {
+			return fmt.Errorf("camera %s: objects min_confidence must be 0-1", c.Name)
+		}
+	}
+	if mode := c.RecordingMode(); mode != RecordContinuous && mode != RecordEvents {
+		return fmt.Errorf("camera %s: record_mode must be %s or %s", c.Name, RecordContinuous, RecordEvents)
+	}
+	if c.RecordingMode() == RecordEvents && c.SingleIngest {
+		return fmt.Errorf("camera %s: single_ingest can't be used with record_mode %s", c.Name, RecordEvents)
+	}
+	if c.PreRoll < 0 || c.PostRoll < 0 {
+		return fmt.Errorf("camera %s: pre_roll and post_roll can't be negative", c.Name)
+	}
+	if c.SceneThreshold != -1 && (c.SceneThreshold < 0 || c.SceneThreshold > 1) {
+		return fmt.Errorf("camera %s: scene_threshold must be 0-1, or -1 to disable", c.Name)
+	}
+	return nil
+}
+
+// SaveCameras replaces the cameras section of the config file at path,
+// keeping the rest of the file (including comments) as it is. The file is
+// replaced atomically.
+func SaveCameras(path string, cameras []CameraConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing config: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("config file is not a YAML mapping")
+	}
+
+	var camerasNode yaml.Node
+	if err := camerasNode.Encode(cameras); err != nil {
+		return fmt.Errorf("encoding cameras: %w", err)
+	}
+
+	root := doc.Content[0]
+	replaced := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "cameras" {
+			// Keep comments attached to the old section
+			camerasNode.HeadComment = root.Content[i+1].HeadComment
+			root.Content[i+1] = &camerasNode
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "cameras"}, &camerasNode)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	encoder.Close()
+
+	// Write next to the original and rename, so a crash never leaves a
+	// half-written config behind
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat config file: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("replacing config: %w", err)
+	}
+
 	return nil
-}
\ No newline at end of file
+}
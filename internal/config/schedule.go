@@ -0,0 +1,176 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScheduleConfig limits a camera's recording to weekly time ranges. Live view
+// is not affected.
+type ScheduleConfig struct {
+	Timezone string          `yaml:"timezone,omitempty" json:"timezone,omitempty"` // IANA name, e.g. "Europe/Berlin" (default: system timezone)
+	Ranges   []ScheduleRange `yaml:"ranges" json:"ranges"`
+}
+
+// ScheduleRange is a daily time range on some days of the week
+type ScheduleRange struct {
+	Days  []string `yaml:"days,omitempty" json:"days,omitempty"` // mon..sun, weekdays, weekends (default: every day)
+	Start string   `yaml:"start" json:"start"`                   // HH:MM
+	End   string   `yaml:"end" json:"end"`                       // HH:MM, before start to run past midnight
+}
+
+// scheduleDays maps day names to the weekdays they cover
+var scheduleDays = map[string][]time.Weekday{
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+	"daily":    {time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday},
+}
+
+// compiledRange is a ScheduleRange in minutes of the day
+type compiledRange struct {
+	days  [7]bool
+	start int
+	end   int
+}
+
+// Validate checks that the schedule can be evaluated
+func (s *ScheduleConfig) Validate() error {
+	_, _, err := s.compile()
+	return err
+}
+
+// Active reports whether recording is scheduled at t
+func (s *ScheduleConfig) Active(t time.Time) bool {
+	ranges, loc, err := s.compile()
+	if err != nil {
+		// Validated configs never get here; record rather than miss footage
+		return true
+	}
+	return activeAt(ranges, t.In(loc))
+}
+
+// NextChange returns when the schedule next switches between on and off
+// after t, or the zero time if it never does
+func (s *ScheduleConfig) NextChange(t time.Time) time.Time {
+	ranges, loc, err := s.compile()
+	if err != nil {
+		return time.Time{}
+	}
+
+	// Ranges have minute resolution, so step through the coming week minute by minute
+	active := activeAt(ranges, t.In(loc))
+	next := t.Truncate(time.Minute)
+	for i := 0; i < 8*24*60; i++ {
+		next = next.Add(time.Minute)
+		if activeAt(ranges, next.In(loc)) != active {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// OffPeriods returns the periods between from and to when recording is not
+// scheduled
+func (s *ScheduleConfig) OffPeriods(from, to time.Time) [][2]time.Time {
+	var periods [][2]time.Time
+	for t := from; t.Before(to); {
+		next := s.NextChange(t)
+		if next.IsZero() || next.After(to) {
+			next = to
+		}
+		if !s.Active(t) {
+			periods = append(periods, [2]time.Time{t, next})
+		}
+		t = next
+	}
+	return periods
+}
+
+// compile parses the schedule's location and ranges
+func (s *ScheduleConfig) compile() ([]compiledRange, *time.Location, error) {
+	loc := time.Local
+	if s.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, nil, fmt.Errorf("invalid timezone %q", s.Timezone)
+		}
+	}
+
+	if len(s.Ranges) == 0 {
+		return nil, nil, fmt.Errorf("schedule needs at least one range")
+	}
+
+	ranges := make([]compiledRange, 0, len(s.Ranges))
+	for _, r := range s.Ranges {
+		var c compiledRange
+		var err error
+		if c.start, err = parseClock(r.Start); err != nil {
+			return nil, nil, err
+		}
+		if c.end, err = parseClock(r.End); err != nil {
+			return nil, nil, err
+		}
+
+		if len(r.Days) == 0 {
+			c.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		for _, name := range r.Days {
+			days, ok := scheduleDays[strings.ToLower(name)]
+			if !ok {
+				return nil, nil, fmt.Errorf("invalid schedule day %q", name)
+			}
+			for _, d := range days {
+				c.days[d] = true
+			}
+		}
+
+		ranges = append(ranges, c)
+	}
+
+	return ranges, loc, nil
+}
+
+// activeAt reports whether any range covers local time t
+func activeAt(ranges []compiledRange, t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	for _, r := range ranges {
+		switch {
+		case r.start < r.end:
+			if r.days[today] && minute >= r.start && minute < r.end {
+				return true
+			}
+		case r.start > r.end:
+			// Runs past midnight: the tail belongs to the previous day
+			if (r.days[today] && minute >= r.start) || (r.days[yesterday] && minute < r.end) {
+				return true
+			}
+		default:
+			// Equal start and end means the whole day
+			if r.days[today] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseClock parses HH:MM (or 24:00) into minutes of the day
+func parseClock(value string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil ||
+		hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid schedule time %q, use HH:MM", value)
+	}
+	return hour*60 + minute, nil
+}
@@ -0,0 +1,93 @@
+package detect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DeepStack talks to a DeepStack-style detection API, which CodeProject.AI
+// also serves. Images are posted as the multipart field "image" to an
+// endpoint such as /v1/vision/detection.
+type DeepStack struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewDeepStack creates a detector for the endpoint at url. apiKey may be
+// empty.
+func NewDeepStack(url, apiKey string, timeout time.Duration) *DeepStack {
+	return &DeepStack{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// deepStackResponse is the detection API's reply
+type deepStackResponse struct {
+	Success     bool   `json:"success"`
+	Error       string `json:"error"`
+	Predictions []struct {
+		Label      string  `json:"label"`
+		Confidence float64 `json:"confidence"`
+	} `json:"predictions"`
+}
+
+// Detect sends an image to the detection API
+func (d *DeepStack) Detect(ctx context.Context, image []byte) ([]Detection, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("image", "image.jpg")
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	part.Write(image)
+	if d.apiKey != "" {
+		form.WriteField("api_key", d.apiKey)
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, &body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("reading reply: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("detector returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var result deepStackResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parsing reply: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("detector failed: %s", result.Error)
+	}
+
+	detections := make([]Detection, 0, len(result.Predictions))
+	for _, p := range result.Predictions {
+		detections = append(detections, Detection{Label: p.Label, Confidence: p.Confidence})
+	}
+	return detections, nil
+}
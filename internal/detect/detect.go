@@ -0,0 +1,61 @@
+// Package detect finds objects such as people and cars in camera images. The
+// work is done by a detection service, usually on another machine.
+package detect
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Detection is an object found in an image
+type Detection struct {
+	Label      string  `json:"label"`      // Such as person, car or dog
+	Confidence float64 `json:"confidence"` // 0-1
+}
+
+// Detector finds objects in images
+type Detector interface {
+	// Detect returns the objects found in a JPEG image
+	Detect(ctx context.Context, image []byte) ([]Detection, error)
+}
+
+// New creates the detector a config describes
+func New(cfg config.DetectorConfig) Detector {
+	if cfg.DetectorType() == config.DetectorMock {
+		return NewMock(cfg.MockLabels)
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return NewDeepStack(cfg.URL, cfg.APIKey, timeout)
+}
+
+// Merge adds detections to found, keeping the highest confidence of each
+// label, and returns them most confident first
+func Merge(found, detections []Detection) []Detection {
+	best := make(map[string]float64, len(found)+len(detections))
+	for _, d := range append(append([]Detection(nil), found...), detections...) {
+		if c, ok := best[d.Label]; !ok || d.Confidence > c {
+			best[d.Label] = d.Confidence
+		}
+	}
+
+	merged := make([]Detection, 0, len(best))
+	for label, confidence := range best {
+		merged = append(merged, Detection{Label: label, Confidence: confidence})
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Confidence != merged[j].Confidence {
+			return merged[i].Confidence > merged[j].Confidence
+		}
+		return merged[i].Label < merged[j].Label
+	})
+	return merged
+}
@@ -0,0 +1,34 @@
+package detect
+
+import "context"
+
+// mockConfidence is the confidence of every mock detection
+const mockConfidence = 0.9
+
+// Mock reports the same labels for every image, for trying out label filters
+// and alerts without a detection service
+type Mock struct {
+	labels []string
+}
+
+// NewMock creates a mock detector that finds labels in every image. No
+// labels means a person.
+func NewMock(labels []string) *Mock {
+	if len(labels) == 0 {
+		labels = []string{"person"}
+	}
+	return &Mock{labels: labels}
+}
+
+// Detect reports the mock's labels
+func (m *Mock) Detect(ctx context.Context, image []byte) ([]Detection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	detections := make([]Detection, len(m.labels))
+	for i, label := range m.labels {
+		detections[i] = Detection{Label: label, Confidence: mockConfidence}
+	}
+	return detections, nil
+}
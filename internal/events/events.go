@@ -0,0 +1,232 @@
+// Package events records what happens on each camera, such as motion, so
+// incidents can be found without scrubbing through recordings
+package events
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/detect"
+)
+
+// eventsDir is the folder under a camera's storage folder that holds its
+// events, one JSON Lines file per day
+const eventsDir = "events"
+
+// Event types
+const (
+	TypeMotion = "motion"
+	TypeTamper = "tamper"
+	TypeAudio  = "audio"
+)
+
+// Event is something that happened on a camera. Events still going on have
+// no end yet.
+type Event struct {
+	ID     string     `json:"id"`
+	Camera string     `json:"camera"`
+	Type   string     `json:"type"`
+	Start  time.Time  `json:"start"`
+	End    *time.Time `json:"end,omitempty"`
+	Zones  []string   `json:"zones,omitempty"`  // Motion zones that saw movement
+	Score  float64    `json:"score,omitempty"`  // Peak share of changed pixels, 0-1
+	Reason string     `json:"reason,omitempty"` // What was seen, such as a black image
+	Level  float64    `json:"level,omitempty"`  // Peak loudness in dBFS, for audio events
+
+	// Objects the detector found during motion, most confident first
+	Objects []detect.Detection `json:"objects,omitempty"`
+}
+
+// Query selects events that overlap a time range
+type Query struct {
+	Camera string // Empty for all cameras
+	Type   string // Empty for all types
+	Label  string // Detected object label, empty for any
+	From   time.Time
+	To     time.Time
+}
+
+// Store keeps events on disk next to the recordings. Each day's events are
+// appended to <base>/<camera>/events/<date>.jsonl; an event that changes is
+// appended again, and the last copy wins.
+type Store struct {
+	basePath string
+	logger   *log.Logger
+	mu       sync.Mutex
+}
+
+// Open returns the event store under basePath
+func Open(basePath string) *Store {
+	return &Store{
+		basePath: basePath,
+		logger:   log.New(os.Stdout, "[Events] ", log.LstdFlags),
+	}
+}
+
+// Add stores a new event, giving it an ID
+func (s *Store) Add(ev Event) (Event, error) {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ev, fmt.Errorf("generating event ID: %w", err)
+	}
+	ev.ID = hex.EncodeToString(id[:])
+	return ev, s.write(ev)
+}
+
+// Update stores the new state of an event, such as its end
+func (s *Store) Update(ev Event) error {
+	return s.write(ev)
+}
+
+// write appends an event to the file of the day it started
+func (s *Store) write(ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := filepath.Join(s.basePath, ev.Camera, eventsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating events directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, ev.Start.Format("2006-01-02")+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening events file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing event: %w", err)
+	}
+	return nil
+}
+
+// Find returns the events matching q, oldest first
+func (s *Store) Find(q Query) ([]Event, error) {
+	cameras := []string{q.Camera}
+	if q.Camera == "" {
+		paths, err := filepath.Glob(filepath.Join(s.basePath, "*", eventsDir))
+		if err != nil {
+			return nil, err
+		}
+		cameras = cameras[:0]
+		for _, path := range paths {
+			cameras = append(cameras, filepath.Base(filepath.Dir(path)))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Events are filed under the day they started, which may be the day
+	// before the range if they ran past midnight
+	firstDay := dayStart(q.From.Local().AddDate(0, 0, -1))
+	lastDay := q.To.Local()
+
+	found := []Event{}
+	for _, camera := range cameras {
+		entries, err := os.ReadDir(filepath.Join(s.basePath, camera, eventsDir))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("reading events directory: %w", err)
+		}
+
+		for _, entry := range entries {
+			date := strings.TrimSuffix(entry.Name(), ".jsonl")
+			day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+			if err != nil || day.Before(firstDay) || day.After(lastDay) {
+				continue
+			}
+
+			events, err := s.readDay(camera, date)
+			if err != nil {
+				return nil, err
+			}
+			for _, ev := range events {
+				if matches(ev, q) {
+					found = append(found, ev)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Start.Before(found[j].Start)
+	})
+	return found, nil
+}
+
+// readDay reads one day's events of a camera. Caller must hold s.mu.
+func (s *Store) readDay(camera, date string) ([]Event, error) {
+	f, err := os.Open(filepath.Join(s.basePath, camera, eventsDir, date+".jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("opening events file: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	index := make(map[string]int) // ID -> position in events
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			// A crash can leave a partial last line
+			s.logger.Printf("Skipping bad event in %s/%s: %v", camera, date, err)
+			continue
+		}
+		if i, ok := index[ev.ID]; ok {
+			events[i] = ev
+			continue
+		}
+		index[ev.ID] = len(events)
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading events file: %w", err)
+	}
+	return events, nil
+}
+
+// matches reports whether an event overlaps the query's time range and has
+// its type
+func matches(ev Event, q Query) bool {
+	if q.Type != "" && ev.Type != q.Type {
+		return false
+	}
+	if q.Label != "" && !hasLabel(ev, q.Label) {
+		return false
+	}
+	if ev.Start.After(q.To) {
+		return false
+	}
+	return ev.End == nil || !ev.End.Before(q.From)
+}
+
+// hasLabel reports whether the detector found an object with label
+func hasLabel(ev Event, label string) bool {
+	for _, object := range ev.Objects {
+		if object.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+// dayStart returns midnight of t's day
+func dayStart(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
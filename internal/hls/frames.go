@@ -0,0 +1,124 @@
+package hls
+
+import "time"
+
+// Frame is one video frame of the live stream, for outputs that need the
+// elementary stream rather than HLS segments
+type Frame struct {
+	PTS      int64 // 90kHz clock
+	Keyframe bool
+	Data     []byte // Annex B byte stream
+}
+
+// Video codecs reported by VideoCodec
+const (
+	CodecH264  = "h264"
+	CodecHEVC  = "hevc"
+	CodecMPEG2 = "mpeg2"
+)
+
+// subscriber receives frames from a segmenter
+type subscriber struct {
+	frames       chan Frame
+	waitKeyframe bool // Set until the first keyframe, and after dropping a frame
+}
+
+// VideoCodec returns the codec of the video stream, or "" until the stream
+// layout is known
+func (s *Segmenter) VideoCodec() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch s.videoType {
+	case streamTypeH264:
+		return CodecH264
+	case streamTypeHEVC:
+		return CodecHEVC
+	case streamTypeMPEG2:
+		return CodecMPEG2
+	}
+	return ""
+}
+
+// InputFormat returns FFmpeg's raw input format for a video codec
+func InputFormat(codec string) (string, bool) {
+	switch codec {
+	case CodecH264:
+		return "h264", true
+	case CodecHEVC:
+		return "hevc", true
+	case CodecMPEG2:
+		return "mpegvideo", true
+	}
+	return "", false
+}
+
+// Keyframe returns the latest whole keyframe, if one arrived within maxAge
+func (s *Segmenter) Keyframe(maxAge time.Duration) (Frame, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.keyframe.Data == nil || time.Since(s.keyframeAt) > maxAge {
+		return Frame{}, false
+	}
+	return s.keyframe, true
+}
+
+// Subscribe returns a channel of the video frames as they arrive, starting
+// at the next keyframe. A subscriber that falls more than buffer frames
+// behind skips ahead to the next keyframe. Call the returned function to
+// unsubscribe.
+func (s *Segmenter) Subscribe(buffer int) (<-chan Frame, func()) {
+	sub := &subscriber{
+		frames:       make(chan Frame, buffer),
+		waitKeyframe: true,
+	}
+
+	s.mu.Lock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[*subscriber]struct{})
+	}
+	s.subscribers[sub] = struct{}{}
+	s.mu.Unlock()
+
+	return sub.frames, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subscribers[sub]; ok {
+			delete(s.subscribers, sub)
+			close(sub.frames)
+		}
+	}
+}
+
+// publish hands the frame assembled so far to subscribers and starts
+// assembling the one beginning with data. Keyframes are always assembled,
+// other frames only while anyone is subscribed. Caller must hold s.mu.
+func (s *Segmenter) publish(pts int64, keyframe bool, data []byte) {
+	if len(s.frame) > 0 {
+		frame := Frame{PTS: s.framePTS, Keyframe: s.frameKey, Data: s.frame}
+		if frame.Keyframe {
+			s.keyframe = frame
+			s.keyframeAt = time.Now()
+		}
+		for sub := range s.subscribers {
+			if sub.waitKeyframe && !frame.Keyframe {
+				continue
+			}
+			select {
+			case sub.frames <- frame:
+				sub.waitKeyframe = false
+			default:
+				sub.waitKeyframe = true
+			}
+		}
+	}
+
+	if len(s.subscribers) == 0 && !keyframe {
+		s.frame = nil
+		return
+	}
+	s.frame = append(make([]byte, 0, 64*packetSize), data...)
+	s.framePTS = pts
+	s.frameKey = keyframe
+}
@@ -0,0 +1,491 @@
+// Package hls turns a live MPEG-TS stream into HLS segments held in memory
+package hls
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// spareSegments are kept after they leave the playlist, so players that
+	// just loaded the playlist can still fetch them
+	spareSegments = 2
+
+	// maxSegmentSize drops a segment that grows this large without a
+	// keyframe to end it
+	maxSegmentSize = 64 << 20
+
+	// partHoldBack is how many part targets behind the live edge players
+	// should stay in low-latency mode
+	partHoldBack = 3
+)
+
+// Segment is a finished HLS media segment
+type Segment struct {
+	Sequence      uint64
+	Duration      float64 // Seconds
+	Discontinuity bool    // First segment after the input restarted
+	Data          []byte
+	Parts         []*Part // Partial segments, in low-latency mode
+}
+
+// Part is a partial segment of a low-latency stream. Each part starts with
+// the program tables, and together they make up the whole segment.
+type Part struct {
+	Duration    float64 // Seconds
+	Independent bool    // Starts with a keyframe
+	Data        []byte
+}
+
+// Segmenter cuts an MPEG-TS stream into segments at keyframes and keeps the
+// most recent ones in memory. It implements io.Writer, so FFmpeg's output
+// can be copied into it directly.
+type Segmenter struct {
+	target      time.Duration
+	windowSize  int
+	mu          sync.RWMutex
+	segments    []*Segment
+	nextSeq     uint64
+	discontSeq  uint64 // Discontinuities that left the window
+	lastSegment time.Time
+	updated     chan struct{} // Closed and replaced when a segment or part is added
+
+	// Low-latency state; partTarget is zero when parts are disabled
+	partTarget      time.Duration
+	parts           []*Part // Finished parts of the current segment
+	partStart       int64   // PTS of the current part's first frame
+	partOffset      int     // Start of the current part within current
+	partIndependent bool
+	lastFrame       int64 // PTS of the previous video frame
+
+	// Frame subscribers, fed whole video frames as they arrive
+	subscribers map[*subscriber]struct{}
+	frame       []byte // Frame being assembled, while anyone is subscribed or it's a keyframe
+	framePTS    int64
+	frameKey    bool
+	keyframe    Frame // Latest whole keyframe, for still images
+	keyframeAt  time.Time
+
+	// Parser state
+	buf           []byte // Incomplete packet from the last write
+	pmtPID        uint16
+	videoPID      uint16
+	videoType     byte
+	pat           []byte // Latest PAT and PMT, repeated at the start of each segment
+	pmt           []byte
+	current       []byte // Segment being built, nil until the first keyframe
+	currentStart  int64  // PTS of the current segment's first keyframe
+	discontinuity bool
+}
+
+// NewSegmenter creates a segmenter that cuts segments of about target
+// length and lists windowSize of them in the playlist. A non-zero partTarget
+// enables Low-Latency HLS, splitting segments into parts of at most that
+// length.
+func NewSegmenter(target, partTarget time.Duration, windowSize int) *Segmenter {
+	return &Segmenter{
+		target:     target,
+		partTarget: partTarget,
+		windowSize: windowSize,
+		updated:    make(chan struct{}),
+	}
+}
+
+// TargetDuration returns the target segment length
+func (s *Segmenter) TargetDuration() time.Duration {
+	return s.target
+}
+
+// Write parses MPEG-TS data. It never fails; data it can't make sense of is
+// skipped.
+func (s *Segmenter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := p
+	if len(s.buf) > 0 {
+		data = append(s.buf, p...)
+	}
+
+	for len(data) >= packetSize {
+		if data[0] != syncByte {
+			// Lost sync: skip to the next sync byte
+			i := bytes.IndexByte(data[1:], syncByte)
+			if i < 0 {
+				data = nil
+				break
+			}
+			data = data[i+1:]
+			continue
+		}
+
+		s.packet(data[:packetSize])
+		data = data[packetSize:]
+	}
+
+	s.buf = append(s.buf[:0:0], data...)
+	return len(p), nil
+}
+
+// Discontinue prepares for a new input stream, such as after FFmpeg
+// restarted. Segments already made are kept, and the next one is marked as
+// a discontinuity.
+func (s *Segmenter) Discontinue() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resetParser()
+	s.discontinuity = len(s.segments) > 0
+}
+
+// Clear drops all segments, for when the stream stops. Sequence numbers keep
+// counting up so players never mix up old and new segments.
+func (s *Segmenter) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resetParser()
+	s.segments = nil
+	s.discontinuity = false
+}
+
+// LastSegmentAt returns when the latest segment was finished
+func (s *Segmenter) LastSegmentAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastSegment
+}
+
+// Segment returns a segment by sequence number while it's still held
+func (s *Segmenter) Segment(seq uint64) (*Segment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, seg := range s.segments {
+		if seg.Sequence == seq {
+			return seg, true
+		}
+	}
+	return nil, false
+}
+
+// Recent returns the newest segments that add up to at least d, oldest
+// first. It stops at a discontinuity, since older segments came from a
+// previous input stream.
+func (s *Segmenter) Recent(d time.Duration) []*Segment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := len(s.segments)
+	var total float64
+	for i > 0 && total < d.Seconds() {
+		i--
+		total += s.segments[i].Duration
+		if s.segments[i].Discontinuity {
+			break
+		}
+	}
+	return append([]*Segment(nil), s.segments[i:]...)
+}
+
+// NextSequence returns the sequence number the next finished segment gets
+func (s *Segmenter) NextSequence() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nextSeq
+}
+
+// Part returns a partial segment by sequence number and index while it's
+// still held, including parts of the segment being built
+func (s *Segmenter) Part(seq uint64, index int) (*Part, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	parts := s.parts
+	if seq != s.nextSeq {
+		parts = nil
+		for _, seg := range s.segments {
+			if seg.Sequence == seq {
+				parts = seg.Parts
+				break
+			}
+		}
+	}
+	if index < 0 || index >= len(parts) {
+		return nil, false
+	}
+	return parts[index], true
+}
+
+// Wait blocks until segment seq is finished or, if part isn't negative, has
+// that part, for blocking playlist reloads and preload hints. It reports
+// false if ctx ends first.
+func (s *Segmenter) Wait(ctx context.Context, seq uint64, part int) bool {
+	for {
+		s.mu.RLock()
+		ready := seq < s.nextSeq || (seq == s.nextSeq && part >= 0 && part < len(s.parts))
+		updated := s.updated
+		s.mu.RUnlock()
+
+		if ready {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-updated:
+		}
+	}
+}
+
+// Playlist returns the live playlist, with segment URIs made from uriPrefix
+// and the sequence number. It reports false until a segment is ready.
+func (s *Segmenter) Playlist(uriPrefix string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	window := s.window()
+	if len(window) == 0 {
+		return "", false
+	}
+
+	// Discontinuities in spare segments have left the playlist too
+	discontSeq := s.discontSeq
+	for _, seg := range s.segments[:len(s.segments)-len(window)] {
+		if seg.Discontinuity {
+			discontSeq++
+		}
+	}
+
+	targetDuration := int(math.Ceil(s.target.Seconds()))
+	for _, seg := range window {
+		if d := int(math.Ceil(seg.Duration)); d > targetDuration {
+			targetDuration = d
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	if s.partTarget > 0 {
+		b.WriteString("#EXT-X-VERSION:6\n")
+	} else {
+		b.WriteString("#EXT-X-VERSION:3\n")
+	}
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", targetDuration)
+	if s.partTarget > 0 {
+		fmt.Fprintf(&b, "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=%.3f\n", partHoldBack*s.partTarget.Seconds())
+		fmt.Fprintf(&b, "#EXT-X-PART-INF:PART-TARGET=%.3f\n", s.partTarget.Seconds())
+	}
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", window[0].Sequence)
+	fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", discontSeq)
+
+	// Parts are only listed for the last few target durations of segments
+	partsFrom := len(window)
+	for age := 0.0; partsFrom > 0 && age < float64(partHoldBack*targetDuration); {
+		partsFrom--
+		age += window[partsFrom].Duration
+	}
+
+	for i, seg := range window {
+		if seg.Discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if s.partTarget > 0 && i >= partsFrom {
+			writeParts(&b, uriPrefix, seg.Sequence, seg.Parts)
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s%d.ts\n", seg.Duration, uriPrefix, seg.Sequence)
+	}
+
+	if s.partTarget > 0 {
+		if s.discontinuity && len(s.parts) > 0 {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		writeParts(&b, uriPrefix, s.nextSeq, s.parts)
+		fmt.Fprintf(&b, "#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"%s%d.%d.ts\"\n", uriPrefix, s.nextSeq, len(s.parts))
+	}
+	return b.String(), true
+}
+
+// writeParts lists a segment's parts in a playlist. Part URIs are the
+// segment's with the part index added: <prefix><seq>.<index>.ts
+func writeParts(b *strings.Builder, uriPrefix string, seq uint64, parts []*Part) {
+	for i, part := range parts {
+		fmt.Fprintf(b, "#EXT-X-PART:DURATION=%.3f,URI=\"%s%d.%d.ts\"", part.Duration, uriPrefix, seq, i)
+		if part.Independent {
+			b.WriteString(",INDEPENDENT=YES")
+		}
+		b.WriteString("\n")
+	}
+}
+
+// window returns the segments listed in the playlist. Caller must hold s.mu.
+func (s *Segmenter) window() []*Segment {
+	if len(s.segments) > s.windowSize {
+		return s.segments[len(s.segments)-s.windowSize:]
+	}
+	return s.segments
+}
+
+// packet handles one TS packet. Caller must hold s.mu.
+func (s *Segmenter) packet(pkt []byte) {
+	pid := packetPID(pkt)
+	payload, randomAccess := packetPayload(pkt)
+
+	switch {
+	case pid == 0:
+		if payloadStart(pkt) {
+			if pmtPID := parsePAT(payload); pmtPID != 0 {
+				s.pmtPID = pmtPID
+			}
+		}
+		s.pat = append(s.pat[:0:0], pkt...)
+
+	case pid == s.pmtPID && s.pmtPID != 0:
+		if payloadStart(pkt) {
+			if videoPID, videoType := parsePMT(payload); videoPID != 0 {
+				s.videoPID, s.videoType = videoPID, videoType
+			}
+		}
+		s.pmt = append(s.pmt[:0:0], pkt...)
+
+	case pid == s.videoPID && s.videoPID != 0 && payloadStart(pkt):
+		if pts, es, ok := parsePES(payload); ok {
+			keyframe := randomAccess || containsKeyframe(es, s.videoType)
+			s.publish(pts, keyframe, es)
+			s.cut(pts, keyframe)
+		}
+
+	case pid == s.videoPID && s.videoPID != 0 && s.frame != nil:
+		s.frame = append(s.frame, payload...)
+	}
+
+	if s.current != nil {
+		s.current = append(s.current, pkt...)
+		if len(s.current) > maxSegmentSize {
+			s.current = nil
+			s.parts = nil
+		}
+	}
+}
+
+// cut handles the start of a video frame. A new segment starts at a
+// keyframe once the current one is long enough, and in low-latency mode a
+// new part starts before the current one would grow past the part target.
+// Caller must hold s.mu.
+func (s *Segmenter) cut(pts int64, keyframe bool) {
+	if s.pat == nil || s.pmt == nil {
+		return
+	}
+	lastFrame := s.lastFrame
+	s.lastFrame = pts
+
+	if s.current != nil {
+		duration := ptsSince(pts, s.currentStart)
+		if !keyframe || duration < s.target.Seconds() {
+			if s.partTarget > 0 {
+				// Assume the next frame is as far away as this one was
+				elapsed := ptsSince(pts, s.partStart)
+				if elapsed > 0 && elapsed+ptsSince(pts, lastFrame) > s.partTarget.Seconds() {
+					s.finishPart(pts, keyframe)
+				}
+			}
+			return
+		}
+		s.finish(pts, duration)
+	}
+
+	if !keyframe {
+		return
+	}
+
+	// Each segment starts with the program tables so it decodes on its own
+	s.current = make([]byte, 0, len(s.pat)+len(s.pmt)+64*packetSize)
+	s.current = append(s.current, s.pat...)
+	s.current = append(s.current, s.pmt...)
+	s.currentStart = pts
+	s.partStart = pts
+	s.partOffset = 0
+	s.partIndependent = true
+}
+
+// finishPart ends the current part before the frame at pts and starts the
+// next one. The part's data is capped at its end, so it never changes once
+// published even as the segment grows or is copied. Caller must hold s.mu.
+func (s *Segmenter) finishPart(pts int64, keyframe bool) {
+	end := len(s.current)
+	s.parts = append(s.parts, &Part{
+		Duration:    ptsSince(pts, s.partStart),
+		Independent: s.partIndependent,
+		Data:        s.current[s.partOffset:end:end],
+	})
+	s.notify()
+
+	// Repeating the program tables lets players parse each part on its own
+	s.partOffset = len(s.current)
+	s.current = append(s.current, s.pat...)
+	s.current = append(s.current, s.pmt...)
+	s.partStart = pts
+	s.partIndependent = keyframe
+}
+
+// finish adds the current segment, ending before the frame at pts, to the
+// window. Caller must hold s.mu.
+func (s *Segmenter) finish(pts int64, duration float64) {
+	var parts []*Part
+	if s.partTarget > 0 {
+		// Earlier parts were published as they finished, so only the last
+		// one is new
+		end := len(s.current)
+		parts = append(s.parts, &Part{
+			Duration:    ptsSince(pts, s.partStart),
+			Independent: s.partIndependent,
+			Data:        s.current[s.partOffset:end:end],
+		})
+	}
+
+	s.segments = append(s.segments, &Segment{
+		Sequence:      s.nextSeq,
+		Duration:      duration,
+		Discontinuity: s.discontinuity,
+		Data:          s.current,
+		Parts:         parts,
+	})
+	s.nextSeq++
+	s.discontinuity = false
+	s.lastSegment = time.Now()
+	s.current = nil
+	s.parts = nil
+	s.notify()
+
+	if len(s.segments) > s.windowSize+spareSegments {
+		if s.segments[0].Discontinuity {
+			s.discontSeq++
+		}
+		s.segments = s.segments[1:]
+	}
+}
+
+// notify wakes everyone waiting in Wait. Caller must hold s.mu.
+func (s *Segmenter) notify() {
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// resetParser forgets the stream layout and any partial segment. Caller must
+// hold s.mu.
+func (s *Segmenter) resetParser() {
+	s.buf = nil
+	s.pmtPID = 0
+	s.videoPID = 0
+	s.videoType = 0
+	s.pat = nil
+	s.pmt = nil
+	s.current = nil
+	s.parts = nil
+	s.frame = nil
+}
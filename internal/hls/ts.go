@@ -0,0 +1,159 @@
+package hls
+
+import "encoding/binary"
+
+const (
+	packetSize = 188
+	syncByte   = 0x47
+
+	// ptsClock is the MPEG-TS timestamp rate
+	ptsClock = 90000
+
+	// ptsMask wraps 33-bit timestamps
+	ptsMask = 1<<33 - 1
+)
+
+// MPEG-TS stream types of the video codecs cameras send
+const (
+	streamTypeMPEG2 = 0x02
+	streamTypeH264  = 0x1b
+	streamTypeHEVC  = 0x24
+)
+
+// packetPID returns the PID of a TS packet
+func packetPID(pkt []byte) uint16 {
+	return uint16(pkt[1]&0x1f)<<8 | uint16(pkt[2])
+}
+
+// payloadStart reports whether a TS packet starts a PES packet or section
+func payloadStart(pkt []byte) bool {
+	return pkt[1]&0x40 != 0
+}
+
+// packetPayload returns a TS packet's payload and whether its adaptation
+// field marks a random access point
+func packetPayload(pkt []byte) ([]byte, bool) {
+	control := (pkt[3] >> 4) & 0x3
+	offset := 4
+	randomAccess := false
+
+	if control&0x2 != 0 {
+		length := int(pkt[4])
+		if length > 0 {
+			randomAccess = pkt[5]&0x40 != 0
+		}
+		offset += 1 + length
+	}
+	if control&0x1 == 0 || offset >= packetSize {
+		return nil, randomAccess
+	}
+	return pkt[offset:], randomAccess
+}
+
+// sectionData skips the pointer field of a PSI payload and returns the section
+func sectionData(payload []byte) []byte {
+	if len(payload) < 1 || int(payload[0])+1 > len(payload) {
+		return nil
+	}
+	return payload[1+int(payload[0]):]
+}
+
+// parsePAT returns the PMT PID of the first program in a PAT
+func parsePAT(payload []byte) uint16 {
+	section := sectionData(payload)
+	if len(section) < 8 || section[0] != 0x00 {
+		return 0
+	}
+
+	end := 3 + int(binary.BigEndian.Uint16(section[1:3])&0x0fff) - 4 // Minus CRC
+	if end > len(section) {
+		end = len(section)
+	}
+	for i := 8; i+4 <= end; i += 4 {
+		program := binary.BigEndian.Uint16(section[i : i+2])
+		if program != 0 { // Program 0 points at the network table
+			return binary.BigEndian.Uint16(section[i+2:i+4]) & 0x1fff
+		}
+	}
+	return 0
+}
+
+// parsePMT returns the PID and stream type of the first video stream in a PMT
+func parsePMT(payload []byte) (uint16, byte) {
+	section := sectionData(payload)
+	if len(section) < 12 || section[0] != 0x02 {
+		return 0, 0
+	}
+
+	end := 3 + int(binary.BigEndian.Uint16(section[1:3])&0x0fff) - 4 // Minus CRC
+	if end > len(section) {
+		end = len(section)
+	}
+	i := 12 + int(binary.BigEndian.Uint16(section[10:12])&0x0fff)
+	for i+5 <= end {
+		streamType := section[i]
+		pid := binary.BigEndian.Uint16(section[i+1:i+3]) & 0x1fff
+		switch streamType {
+		case streamTypeMPEG2, streamTypeH264, streamTypeHEVC:
+			return pid, streamType
+		}
+		i += 5 + int(binary.BigEndian.Uint16(section[i+3:i+5])&0x0fff)
+	}
+	return 0, 0
+}
+
+// parsePES returns the PTS of a PES packet starting in payload and the
+// elementary stream data that follows its header
+func parsePES(payload []byte) (int64, []byte, bool) {
+	if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+		return 0, nil, false
+	}
+
+	headerEnd := 9 + int(payload[8])
+	if payload[7]&0x80 == 0 || len(payload) < 14 || headerEnd > len(payload) {
+		return 0, nil, false
+	}
+
+	p := payload[9:14]
+	pts := int64(p[0]>>1&0x07)<<30 | int64(p[1])<<22 | int64(p[2]>>1)<<15 | int64(p[3])<<7 | int64(p[4]>>1)
+	return pts, payload[headerEnd:], true
+}
+
+// containsKeyframe reports whether elementary stream data holds the start of
+// a keyframe. It's the fallback for muxers that don't set random access flags.
+func containsKeyframe(data []byte, streamType byte) bool {
+	for i := 0; i+3 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+
+		header := data[i+3]
+		switch streamType {
+		case streamTypeH264:
+			// IDR slice or sequence parameter set
+			if t := header & 0x1f; t == 5 || t == 7 {
+				return true
+			}
+		case streamTypeHEVC:
+			// IRAP picture or parameter sets
+			if t := (header >> 1) & 0x3f; (t >= 16 && t <= 21) || (t >= 32 && t <= 34) {
+				return true
+			}
+		case streamTypeMPEG2:
+			// Sequence header
+			if header == 0xb3 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ptsSince returns the time between two PTS values, allowing for wraparound
+func ptsSince(pts, start int64) float64 {
+	diff := (pts - start) & ptsMask
+	if diff > ptsMask/2 {
+		return 0 // Timestamps went backwards
+	}
+	return float64(diff) / ptsClock
+}
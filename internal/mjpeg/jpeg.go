@@ -0,0 +1,137 @@
+package mjpeg
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// JPEG markers
+const (
+	markerSOI = 0xD8 // Start of image
+	markerEOI = 0xD9 // End of image
+	markerSOS = 0xDA // Start of scan, followed by entropy-coded data
+)
+
+// maxImageSize guards against runaway reads of a corrupt stream
+const maxImageSize = 16 << 20
+
+// readJPEG reads the next JPEG image from a stream of concatenated images.
+// It follows the marker segments rather than searching for the end marker,
+// since those bytes may also appear inside headers.
+func readJPEG(r *bufio.Reader) ([]byte, error) {
+	// Skip anything before the start of image
+	var prev byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if prev == 0xFF && b == markerSOI {
+			break
+		}
+		prev = b
+	}
+
+	img := []byte{0xFF, markerSOI}
+	var pending byte // Marker that ended the last scan
+	for {
+		marker := pending
+		if marker == 0 {
+			var err error
+			if marker, err = readMarker(r); err != nil {
+				return nil, noEOF(err)
+			}
+		}
+		pending = 0
+		img = append(img, 0xFF, marker)
+
+		switch {
+		case marker == markerEOI:
+			return img, nil
+		case marker >= 0xD0 && marker <= 0xD7, marker == 0x01:
+			// Restart and TEM markers have no payload
+			continue
+		}
+
+		// Marker segments start with their length, which counts itself
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return nil, noEOF(err)
+		}
+		size := int(length[0])<<8 | int(length[1])
+		if size < 2 || len(img)+size > maxImageSize {
+			return nil, errors.New("invalid JPEG segment length")
+		}
+		img = append(img, length[:]...)
+		start := len(img)
+		img = append(img, make([]byte, size-2)...)
+		if _, err := io.ReadFull(r, img[start:]); err != nil {
+			return nil, noEOF(err)
+		}
+
+		if marker == markerSOS {
+			var err error
+			if img, pending, err = readScan(r, img); err != nil {
+				return nil, noEOF(err)
+			}
+		}
+	}
+}
+
+// readMarker reads the next marker code, skipping fill bytes
+func readMarker(r *bufio.Reader) (byte, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, errors.New("expected JPEG marker")
+	}
+	for {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		if b != 0xFF {
+			return b, nil
+		}
+	}
+}
+
+// readScan appends entropy-coded data to img and returns the marker that
+// ends it. Inside the data, 0xFF is followed by 0x00 or a restart marker.
+func readScan(r *bufio.Reader, img []byte) ([]byte, byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, 0, err
+		}
+		if b != 0xFF {
+			img = append(img, b)
+			if len(img) > maxImageSize {
+				return nil, 0, errors.New("JPEG image too large")
+			}
+			continue
+		}
+
+		next, err := r.ReadByte()
+		for err == nil && next == 0xFF {
+			next, err = r.ReadByte()
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if next != 0x00 && (next < 0xD0 || next > 0xD7) {
+			return img, next, nil
+		}
+		img = append(img, b, next)
+	}
+}
+
+// noEOF reports a stream that ends inside an image as unexpected
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
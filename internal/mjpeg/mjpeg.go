@@ -0,0 +1,277 @@
+// Package mjpeg turns live camera video into Motion JPEG, for clients such as
+// old tablets and dashboards that can't play HLS
+package mjpeg
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+)
+
+const (
+	defaultFPS   = 2
+	defaultWidth = 640
+
+	// jpegQuality is FFmpeg's -q:v, from 2 (best) to 31
+	jpegQuality = 5
+
+	// frameBuffer is how many video frames the encoder may fall behind
+	// before it skips to the next keyframe
+	frameBuffer = 30
+
+	// startTimeout is how long to wait for the first keyframe, which may
+	// need an on-demand live stream to start first
+	startTimeout = 15 * time.Second
+)
+
+// Server shares one JPEG encoder per camera among all its clients
+type Server struct {
+	fps     int
+	scale   string // FFmpeg scale filter argument, "" for camera resolution
+	logger  *log.Logger
+	mu      sync.Mutex
+	streams map[*hls.Segmenter]*stream
+}
+
+// stream is one camera's running JPEG encoder
+type stream struct {
+	camera  string
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+// NewServer creates an MJPEG server with the given settings
+func NewServer(cfg config.MJPEGConfig) *Server {
+	fps := cfg.FPS
+	if fps == 0 {
+		fps = defaultFPS
+	}
+
+	// -2 keeps the aspect ratio with an even size, which encoders prefer
+	width, height := cfg.Width, cfg.Height
+	if width == 0 {
+		width = defaultWidth
+		if height > 0 {
+			width = -2
+		}
+	}
+	scale := ""
+	if width > 0 || height > 0 {
+		if width <= 0 {
+			width = -2
+		}
+		if height <= 0 {
+			height = -2
+		}
+		scale = fmt.Sprintf("%d:%d", width, height)
+	}
+
+	return &Server{
+		fps:     fps,
+		scale:   scale,
+		logger:  log.New(os.Stdout, "[MJPEG] ", log.LstdFlags),
+		streams: make(map[*hls.Segmenter]*stream),
+	}
+}
+
+// Subscribe returns a channel of JPEG images from the source's video. The
+// first client starts an FFmpeg encoder, which stops when the last client
+// unsubscribes. Slow clients skip images. The channel is closed if the
+// encoder stops. Call the returned function to unsubscribe.
+func (s *Server) Subscribe(camera string, source *hls.Segmenter) (<-chan []byte, func()) {
+	images := make(chan []byte, 1)
+
+	s.mu.Lock()
+	st, ok := s.streams[source]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		st = &stream{camera: camera, cancel: cancel, clients: make(map[chan []byte]struct{})}
+		s.streams[source] = st
+		go s.run(ctx, st, source)
+	}
+	st.mu.Lock()
+	st.clients[images] = struct{}{}
+	st.mu.Unlock()
+	s.mu.Unlock()
+
+	return images, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		if _, ok := st.clients[images]; ok {
+			delete(st.clients, images)
+			close(images)
+		}
+		if len(st.clients) == 0 {
+			st.cancel()
+			if s.streams[source] == st {
+				delete(s.streams, source)
+			}
+		}
+	}
+}
+
+// run encodes the source's video to JPEG images until it's cancelled or
+// FFmpeg fails, then disconnects the remaining clients
+func (s *Server) run(ctx context.Context, st *stream, source *hls.Segmenter) {
+	defer func() {
+		s.mu.Lock()
+		if s.streams[source] == st {
+			delete(s.streams, source)
+		}
+		s.mu.Unlock()
+		st.closeClients()
+	}()
+
+	if err := s.encode(ctx, st, source); err != nil && ctx.Err() == nil {
+		s.logger.Printf("❌ %s: %v", st.camera, err)
+	}
+}
+
+// encode pipes the source's video frames through FFmpeg and hands each JPEG
+// image to the clients
+func (s *Server) encode(ctx context.Context, st *stream, source *hls.Segmenter) error {
+	frames, unsubscribe := source.Subscribe(frameBuffer)
+	defer unsubscribe()
+
+	var first hls.Frame
+	select {
+	case first = <-frames:
+	case <-time.After(startTimeout):
+		return errors.New("timed out waiting for video")
+	case <-ctx.Done():
+		return nil
+	}
+
+	input, ok := hls.InputFormat(source.VideoCodec())
+	if !ok {
+		return fmt.Errorf("unsupported video codec %q", source.VideoCodec())
+	}
+
+	filter := "fps=" + strconv.Itoa(s.fps)
+	if s.scale != "" {
+		filter += ",scale=" + s.scale
+	}
+	// Old MJPEG decoders only handle full-range YUV
+	filter += ",format=yuvj420p"
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-loglevel", "error",
+
+		// Raw video has no timestamps, so the fps filter goes by arrival
+		"-fflags", "nobuffer",
+		"-flags", "low_delay",
+		"-use_wallclock_as_timestamps", "1",
+		"-f", input,
+		"-i", "pipe:0",
+
+		"-an",
+		"-vf", filter,
+		"-c:v", "mjpeg",
+		"-q:v", strconv.Itoa(jpegQuality),
+		"-f", "image2pipe",
+		"pipe:1",
+	)
+	cmd.Stderr = &logWriter{logger: s.logger, camera: st.camera}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("creating FFmpeg input: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("creating FFmpeg output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting FFmpeg: %w", err)
+	}
+	s.logger.Printf("🖼️  %s: MJPEG encoder started (%d fps)", st.camera, s.fps)
+	defer s.logger.Printf("⏹️  %s: MJPEG encoder stopped", st.camera)
+
+	go func() {
+		defer stdin.Close()
+		if _, err := stdin.Write(first.Data); err != nil {
+			return
+		}
+		for {
+			select {
+			case frame, ok := <-frames:
+				if !ok {
+					return
+				}
+				if _, err := stdin.Write(frame.Data); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	reader := bufio.NewReaderSize(stdout, 64*1024)
+	for {
+		img, err := readJPEG(reader)
+		if err != nil {
+			// Wait closes stdout, so finish reading first
+			io.Copy(io.Discard, reader)
+			if waitErr := cmd.Wait(); waitErr != nil {
+				return fmt.Errorf("FFmpeg exited: %w", waitErr)
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("reading JPEG: %w", err)
+		}
+		st.broadcast(img)
+	}
+}
+
+// broadcast hands an image to every client that's ready for one
+func (st *stream) broadcast(img []byte) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for client := range st.clients {
+		select {
+		case client <- img:
+		default:
+		}
+	}
+}
+
+// closeClients disconnects all clients
+func (st *stream) closeClients() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for client := range st.clients {
+		delete(st.clients, client)
+		close(client)
+	}
+}
+
+// logWriter logs FFmpeg's error output
+type logWriter struct {
+	logger *log.Logger
+	camera string
+}
+
+func (lw *logWriter) Write(p []byte) (int, error) {
+	lw.logger.Printf("[%s] FFmpeg: %s", lw.camera, p)
+	return len(p), nil
+}
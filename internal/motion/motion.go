@@ -0,0 +1,226 @@
+// Package motion detects movement by comparing consecutive low-resolution
+// grayscale frames
+package motion
+
+import (
+	"math"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+)
+
+// Width and Height are the size of the grayscale frames the detector
+// compares, one byte per pixel. The image is squeezed to this size whatever
+// its aspect ratio, which zones allow for by using fractions.
+const (
+	Width  = 160
+	Height = 90
+)
+
+const (
+	// pixelThreshold is how much a pixel's brightness must change to count
+	// as movement, which keeps sensor noise out
+	pixelThreshold = 25
+
+	// startFrames is how many frames in a row must show movement to start
+	// an event, so a single glitch doesn't
+	startFrames = 2
+
+	// lightingChange is the share of the whole image above which a change
+	// is taken for lighting, such as night vision switching on
+	lightingChange = 0.8
+
+	defaultSensitivity = 50
+	defaultCooldown    = 10 * time.Second
+)
+
+// zone is a watched rectangle in frame pixels
+type zone struct {
+	name           string
+	x0, y0, x1, y1 int
+}
+
+// Change is an event starting or ending
+type Change struct {
+	Started bool
+	Start   time.Time
+	End     time.Time // Last movement seen, once ended
+	Zones   []string  // Named zones that saw movement
+	Score   float64   // Peak share of a zone's pixels that changed
+}
+
+// Detector turns a stream of frames into motion events
+type Detector struct {
+	zones     []zone
+	threshold float64 // Share of a zone's pixels that must change
+	cooldown  time.Duration
+
+	prev       []byte
+	streak     int // Frames in a row with movement
+	active     bool
+	start      time.Time
+	lastMotion time.Time
+	peak       float64
+	seen       map[string]bool // Zones with movement during the event
+}
+
+// NewDetector creates a detector with a camera's motion settings
+func NewDetector(cfg config.MotionConfig) *Detector {
+	sensitivity := cfg.Sensitivity
+	if sensitivity == 0 {
+		sensitivity = defaultSensitivity
+	}
+	cooldown := time.Duration(cfg.Cooldown) * time.Second
+	if cooldown == 0 {
+		cooldown = defaultCooldown
+	}
+
+	d := &Detector{
+		// From 2% of a zone at sensitivity 1 down to 0.02% at 100
+		threshold: float64(101-sensitivity) / 5000,
+		cooldown:  cooldown,
+	}
+
+	for _, z := range cfg.Zones {
+		zn := zone{
+			name: z.Name,
+			x0:   int(math.Round(z.X * Width)),
+			y0:   int(math.Round(z.Y * Height)),
+			x1:   int(math.Round((z.X + z.Width) * Width)),
+			y1:   int(math.Round((z.Y + z.Height) * Height)),
+		}
+		// Tiny zones still cover at least one pixel
+		zn.x0 = min(zn.x0, Width-1)
+		zn.y0 = min(zn.y0, Height-1)
+		zn.x1 = min(max(zn.x1, zn.x0+1), Width)
+		zn.y1 = min(max(zn.y1, zn.y0+1), Height)
+		d.zones = append(d.zones, zn)
+	}
+	if len(d.zones) == 0 {
+		d.zones = []zone{{x1: Width, y1: Height}}
+	}
+	return d
+}
+
+// Feed compares a frame with the previous one. It returns a change when an
+// event starts, or ends after cooldown without movement.
+func (d *Detector) Feed(frame []byte, now time.Time) (Change, bool) {
+	if len(frame) != Width*Height {
+		return Change{}, false
+	}
+	prev := d.prev
+	d.prev = append(d.prev[:0:0], frame...)
+	if prev == nil {
+		return Change{}, false
+	}
+
+	moving, score, zones := d.compare(prev, frame)
+	if moving {
+		d.streak++
+	} else {
+		d.streak = 0
+	}
+
+	if d.active {
+		if moving {
+			d.lastMotion = now
+			d.peak = max(d.peak, score)
+			for _, name := range zones {
+				d.seen[name] = true
+			}
+			return Change{}, false
+		}
+		if now.Sub(d.lastMotion) >= d.cooldown {
+			return d.end(), true
+		}
+		return Change{}, false
+	}
+
+	if d.streak < startFrames {
+		return Change{}, false
+	}
+
+	d.active = true
+	d.start = now
+	d.lastMotion = now
+	d.peak = score
+	d.seen = make(map[string]bool)
+	for _, name := range zones {
+		d.seen[name] = true
+	}
+	return Change{Started: true, Start: now, Zones: d.seenZones(), Score: score}, true
+}
+
+// Active reports whether an event is in progress
+func (d *Detector) Active() bool {
+	return d.active
+}
+
+// Reset forgets the previous frame, for when the stream restarts, and ends
+// any event in progress
+func (d *Detector) Reset() (Change, bool) {
+	d.prev = nil
+	d.streak = 0
+	if !d.active {
+		return Change{}, false
+	}
+	return d.end(), true
+}
+
+// end finishes the event in progress
+func (d *Detector) end() Change {
+	d.active = false
+	return Change{Start: d.start, End: d.lastMotion, Zones: d.seenZones(), Score: d.peak}
+}
+
+// compare reports whether any zone changed enough between two frames, with
+// the highest share of changed pixels and the named zones that moved
+func (d *Detector) compare(prev, frame []byte) (bool, float64, []string) {
+	changed := make([]bool, len(frame))
+	total := 0
+	for i := range frame {
+		diff := int(frame[i]) - int(prev[i])
+		if diff > pixelThreshold || diff < -pixelThreshold {
+			changed[i] = true
+			total++
+		}
+	}
+	if float64(total) > lightingChange*float64(len(frame)) {
+		return false, 0, nil
+	}
+
+	moving := false
+	score := 0.0
+	var zones []string
+	for _, z := range d.zones {
+		count := 0
+		for y := z.y0; y < z.y1; y++ {
+			for x := z.x0; x < z.x1; x++ {
+				if changed[y*Width+x] {
+					count++
+				}
+			}
+		}
+
+		share := float64(count) / float64((z.x1-z.x0)*(z.y1-z.y0))
+		score = max(score, share)
+		if share >= d.threshold {
+			moving = true
+			if z.name != "" {
+				zones = append(zones, z.name)
+			}
+		}
+	}
+	return moving, score, zones
+}
+
+// seenZones lists the named zones with movement during the event
+func (d *Detector) seenZones() []string {
+	var zones []string
+	for _, z := range d.zones {
+		if d.seen[z.name] && z.name != "" {
+			zones = append(zones, z.name)
+		}
+	}
+	return zones
+}
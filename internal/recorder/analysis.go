@@ -0,0 +1,319 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/events"
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+	"github.com/mmuteeullah/CoreNVR/internal/motion"
+	"github.com/mmuteeullah/CoreNVR/internal/tamper"
+)
+
+const (
+	// analysisFPS is how many frames a second are analysed
+	analysisFPS = 5
+
+	// analysisFrameBuffer is how many video frames the analyser may fall
+	// behind before it skips to the next keyframe
+	analysisFrameBuffer = 30
+
+	// analysisStallTimeout restarts analysis when no video arrives, such as
+	// while the live stream reconnects
+	analysisStallTimeout = 30 * time.Second
+
+	// analysisRetryDelay is how long to wait after analysis fails
+	analysisRetryDelay = 10 * time.Second
+
+	// analysisKeepAliveInterval is how often analysis asks for the stream it
+	// watches, so on-demand streams keep running
+	analysisKeepAliveInterval = 5 * time.Second
+)
+
+// eventStore receives the events recorders detect
+var eventStore *events.Store
+
+// SetEventStore sets where recorders store the events they detect, such as
+// motion. It must be called before any recorder is started.
+func SetEventStore(store *events.Store) {
+	eventStore = store
+}
+
+// analysisPipeline returns the live pipeline video analysis watches: the
+// low-resolution preview stream if there is one
+func (r *Recorder) analysisPipeline() string {
+	if r.camera.PreviewURL != "" {
+		return PipelinePreview
+	}
+	return PipelineLive
+}
+
+// keepStreamAlive keeps an on-demand live pipeline running until ctx is
+// cancelled. Analysis counts as demand without counting as a viewer.
+func (r *Recorder) keepStreamAlive(ctx context.Context, pipeline string) {
+	ticker := time.NewTicker(analysisKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		r.RequestStream(pipeline, "")
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runAnalysis watches the camera for motion and tampering, whichever are
+// enabled, until ctx is cancelled. Both share one decoder.
+func (r *Recorder) runAnalysis(ctx context.Context) {
+	pipeline := r.analysisPipeline()
+	go r.keepStreamAlive(ctx, pipeline)
+
+	var detector *motion.Detector
+	var current *events.Event
+	var motionEnded chan struct{}
+	handle := func(change motion.Change) {
+		current = r.recordMotion(current, change)
+
+		// Frames go to the object detector while the motion lasts
+		if change.Started && current != nil && r.objectsEnabled() {
+			motionEnded = make(chan struct{})
+			go r.detectObjects(ctx, current, motionEnded)
+		}
+		if !change.Started && motionEnded != nil {
+			close(motionEnded)
+			motionEnded = nil
+		}
+	}
+	if r.camera.Motion != nil && r.camera.Motion.Enabled {
+		r.logger.Printf("🏃 Motion detection on %s stream", pipeline)
+		detector = motion.NewDetector(*r.camera.Motion)
+	}
+	if r.camera.Objects != nil && r.camera.Objects.Enabled {
+		if objectDetector == nil {
+			r.logger.Printf("⚠️  Object detection needs the detector enabled in the config")
+		} else {
+			r.logger.Printf("🔎 Object detection on motion")
+		}
+	}
+
+	var tamperDetector *tamper.Detector
+	if r.camera.Tamper != nil && r.camera.Tamper.Enabled {
+		r.logger.Printf("🛡️  Tamper detection on %s stream", pipeline)
+		tamperDetector = tamper.NewDetector(time.Duration(r.camera.Tamper.HoldTime) * time.Second)
+		go r.watchVideoLoss(ctx, tamperDetector)
+	}
+
+	for {
+		err := r.analyze(ctx, r.segmenters[pipeline], func(frame []byte) {
+			now := time.Now()
+			if tamperDetector != nil {
+				if state, ok := tamperDetector.Feed(frame, now); ok {
+					r.setTamper(state)
+				}
+			}
+			if detector == nil {
+				return
+			}
+			if change, ok := detector.Feed(frame, now); ok {
+				handle(change)
+			}
+			// Motion keeps an event recording going
+			if detector.Active() && r.camera.RecordingMode() == config.RecordEvents {
+				r.trigger("motion")
+			}
+		})
+
+		// Motion can't be told apart across a gap in the video
+		if detector != nil {
+			if change, ok := detector.Reset(); ok {
+				handle(change)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.logger.Printf("Video analysis stopped: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(analysisRetryDelay):
+		}
+	}
+}
+
+// recordMotion stores a motion event starting or ending and returns the
+// event in progress, if any
+func (r *Recorder) recordMotion(current *events.Event, change motion.Change) *events.Event {
+	if change.Started {
+		// Paused or scheduled-off cameras keep their privacy
+		if expected, _ := r.RecordingExpected(); !expected {
+			return nil
+		}
+		r.logger.Printf("🏃 Motion started (score %.3f)", change.Score)
+
+		ev := events.Event{
+			Camera: r.camera.Name,
+			Type:   events.TypeMotion,
+			Start:  change.Start,
+			Zones:  change.Zones,
+			Score:  change.Score,
+		}
+		if eventStore == nil {
+			return &ev
+		}
+		stored, err := eventStore.Add(ev)
+		if err != nil {
+			r.logger.Printf("Failed to store motion event: %v", err)
+			return &ev
+		}
+		return &stored
+	}
+
+	if current == nil {
+		return nil
+	}
+	r.logger.Printf("🏃 Motion ended after %v", change.End.Sub(change.Start).Round(time.Second))
+
+	r.motionMu.Lock()
+	defer r.motionMu.Unlock()
+
+	end := change.End
+	current.End = &end
+	current.Zones = change.Zones
+	current.Score = change.Score
+	if eventStore != nil && current.ID != "" {
+		if err := eventStore.Update(*current); err != nil {
+			r.logger.Printf("Failed to store motion event: %v", err)
+		}
+	}
+	return nil
+}
+
+// analyze decodes the source's video to small grayscale frames, motion.Width
+// by motion.Height, and hands each to fn until ctx is cancelled or the video
+// stops
+func (r *Recorder) analyze(ctx context.Context, source *hls.Segmenter, fn func(frame []byte)) error {
+	outputArgs := []string{
+		"-an",
+		"-vf", fmt.Sprintf("fps=%d,scale=%d:%d,format=gray", analysisFPS, motion.Width, motion.Height),
+		"-f", "rawvideo",
+		"pipe:1",
+	}
+
+	return r.analyzeVideo(ctx, source, "ANALYSIS", outputArgs, func(output io.Reader) {
+		frame := make([]byte, motion.Width*motion.Height)
+		for {
+			if _, err := io.ReadFull(output, frame); err != nil {
+				return
+			}
+			fn(frame)
+		}
+	})
+}
+
+// analyzeVideo pipes the source's video frames through FFmpeg with the given
+// output arguments, and hands FFmpeg's output to read, until ctx is cancelled
+// or the video stops. read returns when the output ends.
+func (r *Recorder) analyzeVideo(ctx context.Context, source *hls.Segmenter, prefix string, outputArgs []string, read func(output io.Reader)) error {
+	frames, unsubscribe := source.Subscribe(analysisFrameBuffer)
+	defer unsubscribe()
+
+	var first hls.Frame
+	select {
+	case first = <-frames:
+	case <-time.After(analysisStallTimeout):
+		return errors.New("timed out waiting for video")
+	case <-ctx.Done():
+		return nil
+	}
+
+	input, ok := hls.InputFormat(source.VideoCodec())
+	if !ok {
+		return fmt.Errorf("unsupported video codec %q", source.VideoCodec())
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+
+		// Raw video has no timestamps, so the fps filter goes by arrival
+		"-fflags", "nobuffer",
+		"-flags", "low_delay",
+		"-use_wallclock_as_timestamps", "1",
+		"-f", input,
+		"-i", "pipe:0",
+	}
+	cmd := exec.CommandContext(runCtx, "ffmpeg", append(args, outputArgs...)...)
+	cmd.Stderr = r.stderrWriter(prefix)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("creating FFmpeg input: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("creating FFmpeg output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting FFmpeg: %w", err)
+	}
+
+	// Stops FFmpeg if the video stalls, since it would wait for input forever
+	stalled := make(chan struct{})
+	go func() {
+		defer stdin.Close()
+		if _, err := stdin.Write(first.Data); err != nil {
+			return
+		}
+		timer := time.NewTimer(analysisStallTimeout)
+		defer timer.Stop()
+		for {
+			select {
+			case frame, ok := <-frames:
+				if !ok {
+					return
+				}
+				if _, err := stdin.Write(frame.Data); err != nil {
+					return
+				}
+				timer.Reset(analysisStallTimeout)
+			case <-timer.C:
+				close(stalled)
+				cancel()
+				return
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+
+	read(stdout)
+
+	// Wait closes stdout, so finish reading first
+	io.Copy(io.Discard, stdout)
+	waitErr := cmd.Wait()
+	select {
+	case <-stalled:
+		return fmt.Errorf("no video for %v", analysisStallTimeout)
+	default:
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if waitErr != nil {
+		return fmt.Errorf("FFmpeg exited: %w", waitErr)
+	}
+	return nil
+}
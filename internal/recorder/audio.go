@@ -0,0 +1,240 @@
+package recorder
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/audio"
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/events"
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+)
+
+const (
+	// noAudioRetryDelay is how long to wait before looking again when the
+	// live stream has no audio
+	noAudioRetryDelay = 5 * time.Minute
+
+	// audioClockDrift is how far the sample clock may drift from the wall
+	// clock before it's set again, such as after a gap in the stream
+	audioClockDrift = 10 * time.Second
+)
+
+// errNoAudio means the live stream has no audio to listen to
+var errNoAudio = errors.New("no audio in live stream")
+
+// notify receives messages about events that need attention
+var notify func(message string)
+
+// SetNotifier sets where recorders send events that need attention, such as
+// loud noises and tampering. It must be called before any recorder is started.
+func SetNotifier(fn func(message string)) {
+	notify = fn
+}
+
+// runAudio listens to the camera's audio for loud noises until ctx is
+// cancelled
+func (r *Recorder) runAudio(ctx context.Context) {
+	go r.keepStreamAlive(ctx, PipelineLive)
+
+	detector := audio.NewDetector(*r.camera.Audio)
+	r.logger.Printf("🔊 Audio monitoring on live stream (loud above %.0f dBFS)", detector.Threshold())
+
+	var current *events.Event
+	warned := false
+	for {
+		err := r.listen(ctx, r.segmenters[PipelineLive], func(samples []int16, at time.Time) {
+			if change, ok := detector.Feed(samples, at); ok {
+				current = r.recordAudio(current, change)
+			}
+			// Noise keeps an event recording going
+			if current != nil && detector.Active() && r.camera.RecordingMode() == config.RecordEvents {
+				r.trigger("audio")
+			}
+		})
+
+		// Loudness can't be told apart across a gap in the audio
+		if change, ok := detector.Reset(); ok {
+			current = r.recordAudio(current, change)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := analysisRetryDelay
+		switch {
+		case errors.Is(err, errNoAudio):
+			if !warned {
+				r.logger.Printf("🔇 No audio in live stream, checking again every %v", noAudioRetryDelay)
+				warned = true
+			}
+			delay = noAudioRetryDelay
+		case err != nil:
+			r.logger.Printf("Audio monitoring stopped: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// recordAudio stores a loud noise starting or ending, sends a notification
+// when one starts, and returns the event in progress, if any
+func (r *Recorder) recordAudio(current *events.Event, change audio.Change) *events.Event {
+	if change.Started {
+		// Paused or scheduled-off cameras keep their privacy
+		if expected, _ := r.RecordingExpected(); !expected {
+			return nil
+		}
+		r.logger.Printf("🔊 Loud noise started (%.1f dBFS)", change.Peak)
+		if notify != nil {
+			notify(fmt.Sprintf("Camera %s loud noise: %.1f dBFS at %s", r.camera.Name, change.Peak, change.Start.Format("15:04:05")))
+		}
+
+		ev := events.Event{
+			Camera: r.camera.Name,
+			Type:   events.TypeAudio,
+			Start:  change.Start,
+			Level:  change.Peak,
+		}
+		if eventStore == nil {
+			return &ev
+		}
+		stored, err := eventStore.Add(ev)
+		if err != nil {
+			r.logger.Printf("Failed to store audio event: %v", err)
+			return &ev
+		}
+		return &stored
+	}
+
+	if current == nil {
+		return nil
+	}
+	r.logger.Printf("🔊 Loud noise ended after %v (peak %.1f dBFS)", change.End.Sub(change.Start).Round(time.Second), change.Peak)
+
+	end := change.End
+	current.End = &end
+	current.Level = change.Peak
+	if eventStore != nil && current.ID != "" {
+		if err := eventStore.Update(*current); err != nil {
+			r.logger.Printf("Failed to store audio event: %v", err)
+		}
+	}
+	return nil
+}
+
+// listen decodes the audio of the source's segments to mono samples at
+// audio.SampleRate, and hands each audio.Window of them to fn with the time
+// it started, until ctx is cancelled or the stream stops
+func (r *Recorder) listen(ctx context.Context, source *hls.Segmenter, fn func(samples []int16, at time.Time)) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Segments hold whole MPEG-TS, so FFmpeg finds the audio itself
+	cmd := exec.CommandContext(runCtx, "ffmpeg",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-f", "mpegts",
+		"-i", "pipe:0",
+		"-vn",
+		"-ac", "1",
+		"-ar", fmt.Sprint(audio.SampleRate),
+		"-f", "s16le",
+		"pipe:1",
+	)
+	cmd.Stderr = r.stderrWriter("AUDIO")
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("creating FFmpeg input: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("creating FFmpeg output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting FFmpeg: %w", err)
+	}
+
+	// Feeds segments as they finish, starting with the next one. Stops
+	// FFmpeg if the stream stalls, since it would wait for input forever.
+	stalled := make(chan struct{})
+	go func() {
+		defer stdin.Close()
+		seq := source.NextSequence()
+		for {
+			waitCtx, cancelWait := context.WithTimeout(runCtx, analysisStallTimeout)
+			ready := source.Wait(waitCtx, seq, -1)
+			cancelWait()
+			if !ready {
+				if runCtx.Err() == nil {
+					close(stalled)
+					cancel()
+				}
+				return
+			}
+
+			seg, ok := source.Segment(seq)
+			if !ok {
+				// Fell out of the window, or the stream restarted
+				seq = source.NextSequence()
+				continue
+			}
+			if _, err := stdin.Write(seg.Data); err != nil {
+				return
+			}
+			seq++
+		}
+	}()
+
+	// Samples arrive a segment at a time, so they're timed by counting
+	// from when the first arrived
+	heard := false
+	buf := make([]byte, audio.WindowSamples*2)
+	samples := make([]int16, audio.WindowSamples)
+	var at time.Time
+	for {
+		if _, err := io.ReadFull(stdout, buf); err != nil {
+			break
+		}
+		heard = true
+		for i := range samples {
+			samples[i] = int16(binary.LittleEndian.Uint16(buf[2*i:]))
+		}
+
+		now := time.Now()
+		if at.IsZero() || at.After(now) || now.Sub(at) > audioClockDrift {
+			at = now.Add(-source.TargetDuration())
+		}
+		fn(samples, at)
+		at = at.Add(audio.Window)
+	}
+
+	// Wait closes stdout, so finish reading first
+	io.Copy(io.Discard, stdout)
+	waitErr := cmd.Wait()
+	select {
+	case <-stalled:
+		return fmt.Errorf("no stream for %v", analysisStallTimeout)
+	default:
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if !heard {
+		return errNoAudio
+	}
+	if waitErr != nil {
+		return fmt.Errorf("FFmpeg exited: %w", waitErr)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package recorder
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+)
+
+const (
+	defaultRetryDelay          = 5 * time.Second
+	defaultRetryMaxDelay       = 5 * time.Minute
+	defaultRetryResetAfter     = 2 * time.Minute
+	defaultMaxConcurrentStarts = 2
+)
+
+// startSlots limits how many FFmpeg processes may be connecting to cameras at
+// once, process-wide. After a power blip every pipeline reconnects at the
+// same moment, which can saturate a small machine.
+var startSlots = make(chan struct{}, defaultMaxConcurrentStarts)
+
+// SetMaxConcurrentStarts sets how many FFmpeg processes may be starting at
+// once. It must be called before any recorder is started.
+func SetMaxConcurrentStarts(n int) {
+	if n <= 0 {
+		n = defaultMaxConcurrentStarts
+	}
+	startSlots = make(chan struct{}, n)
+}
+
+// acquireStartSlot waits for a free startup slot. The returned function
+// releases it and is safe to call more than once.
+func acquireStartSlot(ctx context.Context) (func(), error) {
+	slots := startSlots
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-slots })
+	}, nil
+}
+
+// backoff computes retry delays that double after each consecutive failure,
+// up to a cap, with jitter so pipelines don't reconnect in lockstep
+type backoff struct {
+	initial    time.Duration
+	max        time.Duration
+	resetAfter time.Duration // A run this long is stable and resets the delay
+	attempt    int
+}
+
+// newBackoff creates a backoff from a camera's retry settings
+func newBackoff(camera config.CameraConfig) *backoff {
+	b := &backoff{
+		initial:    time.Duration(camera.RetryDelay) * time.Second,
+		max:        time.Duration(camera.RetryMaxDelay) * time.Second,
+		resetAfter: time.Duration(camera.RetryResetAfter) * time.Second,
+	}
+
+	if b.initial <= 0 {
+		b.initial = defaultRetryDelay
+	}
+	if b.max <= 0 {
+		b.max = defaultRetryMaxDelay
+	}
+	if b.max < b.initial {
+		b.max = b.initial
+	}
+	if b.resetAfter <= 0 {
+		b.resetAfter = defaultRetryResetAfter
+	}
+
+	return b
+}
+
+// next returns the delay before the next retry and advances the backoff.
+// The delay is picked at random between half and all of the exponential
+// delay ("equal jitter").
+func (b *backoff) next() time.Duration {
+	delay := b.initial
+	for i := 0; i < b.attempt && delay < b.max; i++ {
+		delay *= 2
+	}
+	if delay > b.max {
+		delay = b.max
+	}
+	b.attempt++
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// observe resets the backoff if a run lasted long enough to count as stable,
+// and reports whether it did
+func (b *backoff) observe(runTime time.Duration) bool {
+	if runTime < b.resetAfter {
+		return false
+	}
+	b.attempt = 0
+	return true
+}
@@ -0,0 +1,415 @@
+package recorder
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/catalog"
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+	"github.com/mmuteeullah/CoreNVR/internal/motion"
+)
+
+const (
+	defaultPreRoll        = 5 * time.Second
+	defaultPostRoll       = 10 * time.Second
+	defaultSceneThreshold = 0.3
+
+	// bufferSegmentDuration is the shortest piece the event buffer keeps.
+	// Pieces end at keyframes, so clips start at one.
+	bufferSegmentDuration = time.Second
+
+	// clipWaitTimeout ends a clip when the buffer gets no video for this long
+	clipWaitTimeout = 15 * time.Second
+
+	// clipRetryDelay is how long to wait after a clip failed to start
+	clipRetryDelay = 5 * time.Second
+)
+
+// Errors returned by Trigger
+var (
+	ErrNotEventMode = errors.New("camera doesn't record in events mode")
+	ErrRecordingOff = errors.New("recording is paused or scheduled off")
+)
+
+// preRoll returns how much video from before a trigger clips include
+func preRoll(camera config.CameraConfig) time.Duration {
+	if camera.PreRoll == 0 {
+		return defaultPreRoll
+	}
+	return time.Duration(camera.PreRoll) * time.Second
+}
+
+// postRoll returns how long clips run on after the last trigger
+func postRoll(camera config.CameraConfig) time.Duration {
+	if camera.PostRoll == 0 {
+		return defaultPostRoll
+	}
+	return time.Duration(camera.PostRoll) * time.Second
+}
+
+// newEventBuffer creates the in-memory buffer of an event-recording camera.
+// It holds enough pieces to cover the pre-roll, each at least
+// bufferSegmentDuration long.
+func newEventBuffer(camera config.CameraConfig) *hls.Segmenter {
+	pieces := int(math.Ceil(preRoll(camera).Seconds()/bufferSegmentDuration.Seconds())) + 1
+	return hls.NewSegmenter(bufferSegmentDuration, 0, pieces)
+}
+
+// Trigger makes an event-recording camera save a clip, from pre_roll seconds
+// before now until post_roll seconds after the last trigger
+func (r *Recorder) Trigger(reason string) error {
+	if r.eventBuffer == nil {
+		return ErrNotEventMode
+	}
+	if expected, _ := r.RecordingExpected(); !expected {
+		return ErrRecordingOff
+	}
+	r.trigger(reason)
+	return nil
+}
+
+// trigger starts or extends a clip. It does nothing unless the camera
+// records in events mode and recording isn't paused or scheduled off.
+func (r *Recorder) trigger(reason string) {
+	if r.eventBuffer == nil {
+		return
+	}
+	if expected, _ := r.RecordingExpected(); !expected {
+		return
+	}
+
+	until := time.Now().Add(postRoll(r.camera))
+	r.statusMu.Lock()
+	if until.After(r.clipUntil) {
+		r.clipUntil = until
+	}
+	r.triggerReason = reason
+	r.statusMu.Unlock()
+
+	select {
+	case r.clipWake <- struct{}{}:
+	default:
+	}
+}
+
+// triggered reports whether a trigger still wants video, and the latest
+// trigger's reason
+func (r *Recorder) triggered() (bool, string) {
+	r.statusMu.RLock()
+	defer r.statusMu.RUnlock()
+	return time.Now().Before(r.clipUntil), r.triggerReason
+}
+
+// startEventRecording keeps the record stream in the event buffer and saves
+// clips when triggers fire, until ctx is cancelled
+func (r *Recorder) startEventRecording(ctx context.Context) {
+	clipsCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.writeClips(clipsCtx)
+	}()
+
+	if r.camera.SceneThreshold != -1 {
+		go r.runSceneTrigger(clipsCtx)
+	}
+
+	r.runPipeline(ctx, "Event recording", r.bufferRecording, PipelineRecord)
+
+	// Finish the clip being written
+	cancel()
+	wg.Wait()
+
+	// Video and triggers from before recording stopped don't belong in the
+	// next clip
+	r.eventBuffer.Clear()
+	r.statusMu.Lock()
+	r.clipUntil = time.Time{}
+	r.statusMu.Unlock()
+}
+
+// bufferRecording copies the record stream into the event buffer
+func (r *Recorder) bufferRecording(ctx context.Context) error {
+	buffer := r.eventBuffer
+	buffer.Discontinue()
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-rtsp_transport", "tcp",
+		"-i", r.camera.RecordStream(),
+		"-map", "0:v",
+		"-map", "0:a?",
+		"-c:v", "copy",
+		"-c:a", r.recordAudioCodec(),
+	}
+	args = append(args, progressArgs...)
+	args = append(args, "-f", "mpegts")
+	args = append(args, muxerArgs(liveMuxerOptions())...)
+	args = append(args, "pipe:1")
+
+	// Tracked as the recording process so Stop handles it
+	r.recordCmd = exec.CommandContext(ctx, "ffmpeg", args...)
+	r.recordCmd.Stdout = buffer
+	r.recordCmd.Stderr = r.stderrWriter("REC", PipelineRecord)
+
+	progress, err := r.monitorProgress(ctx, r.recordCmd, PipelineRecord)
+	if err != nil {
+		return err
+	}
+
+	if err := r.recordCmd.Start(); err != nil {
+		progress.close()
+		return fmt.Errorf("starting event recording ffmpeg: %w", err)
+	}
+
+	r.logger.Printf("⏺️  Event recording armed (%v pre-roll, %v post-roll)", preRoll(r.camera), postRoll(r.camera))
+	r.setState(StateConnecting, PipelineRecord)
+	progress.start(ctx)
+
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	go r.awaitSegment(runCtx, buffer, PipelineRecord)
+
+	return progress.result(r.recordCmd.Wait())
+}
+
+// writeClips saves a clip whenever a trigger fires, until ctx is cancelled
+func (r *Recorder) writeClips(ctx context.Context) {
+	var next uint64
+	continuing := false
+
+	for {
+		if ok, _ := r.triggered(); !ok {
+			continuing = false
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.clipWake:
+			}
+			continue
+		}
+
+		// A clip that ran long continues in the next file without pre-roll
+		var segments []*hls.Segment
+		if !continuing {
+			segments = r.eventBuffer.Recent(preRoll(r.camera))
+			next = r.eventBuffer.NextSequence()
+			if len(segments) > 0 {
+				next = segments[len(segments)-1].Sequence + 1
+			}
+		}
+
+		var err error
+		next, continuing, err = r.writeClip(ctx, segments, next)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.logger.Printf("Failed to save clip: %v", err)
+			continuing = false
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(clipRetryDelay):
+			}
+		}
+	}
+}
+
+// writeClip saves the given buffered segments, followed by new ones from
+// sequence next on while a trigger wants video, to a recording file. It
+// returns the next segment to write, and whether the clip was split because
+// it reached segment_duration.
+func (r *Recorder) writeClip(ctx context.Context, segments []*hls.Segment, next uint64) (uint64, bool, error) {
+	// Without buffered video, the clip starts with the next segment
+	if len(segments) == 0 {
+		seg, ok := r.nextBufferSegment(ctx, next)
+		if !ok {
+			return next, false, nil
+		}
+		segments = []*hls.Segment{seg}
+		next = seg.Sequence + 1
+	}
+
+	// Segments carry no wall clock time, but the newest one ended when the
+	// buffer last finished a segment
+	var length float64
+	for _, seg := range segments {
+		length += seg.Duration
+	}
+	start := r.eventBuffer.LastSegmentAt().Add(-time.Duration(length * float64(time.Second)))
+	date, filename, err := r.clipFile(start)
+	if err != nil {
+		return next, false, err
+	}
+	path := r.catalog.SegmentPath(r.camera.Name, date, filename)
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-f", "mpegts",
+		"-i", "pipe:0",
+		"-map", "0",
+		"-c", "copy",
+	}
+	if r.camera.RecordContainer() == config.ContainerFMP4 {
+		args = append(args, "-f", "mp4", "-movflags", catalog.FragmentedMP4Flags)
+	} else {
+		args = append(args, "-f", "mpegts")
+	}
+	args = append(args, path)
+
+	// Not tied to ctx, so a clip cut short by shutdown is still finished
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stderr = r.stderrWriter("CLIP")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return next, false, fmt.Errorf("creating FFmpeg input: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return next, false, fmt.Errorf("starting clip ffmpeg: %w", err)
+	}
+
+	_, reason := r.triggered()
+	r.logger.Printf("🎬 Clip started (%s): %s/%s", reason, date, filename)
+	r.catalog.SetActive(r.camera.Name, date, filename)
+
+	writer := bufio.NewWriter(stdin)
+	for _, seg := range segments {
+		writer.Write(seg.Data)
+	}
+
+	maxLength := time.Duration(r.storage.SegmentDuration) * time.Second
+	split := false
+	for {
+		if ok, _ := r.triggered(); !ok || ctx.Err() != nil {
+			break
+		}
+		if maxLength > 0 && length >= maxLength.Seconds() {
+			split = true
+			break
+		}
+
+		seg, ok := r.nextBufferSegment(ctx, next)
+		if !ok || seg.Discontinuity {
+			// The input restarted; the next clip starts afresh
+			break
+		}
+		if _, err := writer.Write(seg.Data); err != nil {
+			break
+		}
+		writer.Flush()
+		length += seg.Duration
+		next = seg.Sequence + 1
+	}
+
+	writer.Flush()
+	stdin.Close()
+	waitErr := cmd.Wait()
+	r.catalog.ClearActive(r.camera.Name)
+	if waitErr != nil {
+		return next, false, fmt.Errorf("clip ffmpeg exited: %w", waitErr)
+	}
+
+	seg, err := r.catalog.AddFile(r.camera.Name, date, filename)
+	if err != nil {
+		return next, split, fmt.Errorf("cataloging clip %s/%s: %w", date, filename, err)
+	}
+	r.logger.Printf("🎬 Clip saved: %s/%s (%v)", date, filename, seg.Duration().Round(time.Second))
+	return next, split, nil
+}
+
+// nextBufferSegment waits for the buffer segment with sequence seq. It
+// reports false if ctx ends, the buffer gets no video for a while, or the
+// segment has already left the buffer.
+func (r *Recorder) nextBufferSegment(ctx context.Context, seq uint64) (*hls.Segment, bool) {
+	waitCtx, cancel := context.WithTimeout(ctx, clipWaitTimeout)
+	defer cancel()
+
+	if !r.eventBuffer.Wait(waitCtx, seq, -1) {
+		return nil, false
+	}
+	return r.eventBuffer.Segment(seq)
+}
+
+// clipFile returns the date folder and file name of a clip starting at
+// start, creating the folder. Clips that start in the same second as an
+// earlier one are moved on a second.
+func (r *Recorder) clipFile(start time.Time) (string, string, error) {
+	for attempt := 0; attempt < 10; attempt++ {
+		at := start.Add(time.Duration(attempt) * time.Second)
+		date := at.Format("2006-01-02")
+		filename := at.Format("15-04-05") + r.camera.SegmentExtension()
+
+		if err := os.MkdirAll(filepath.Join(r.storage.BasePath, r.camera.Name, "recordings", date), 0755); err != nil {
+			return "", "", fmt.Errorf("creating date directory: %w", err)
+		}
+		if _, err := os.Stat(r.catalog.SegmentPath(r.camera.Name, date, filename)); os.IsNotExist(err) {
+			return date, filename, nil
+		}
+	}
+	return "", "", errors.New("no free clip file name")
+}
+
+// runSceneTrigger fires a trigger whenever FFmpeg's scene change score of
+// the analysed stream passes scene_threshold, until ctx is cancelled
+func (r *Recorder) runSceneTrigger(ctx context.Context) {
+	threshold := r.camera.SceneThreshold
+	if threshold == 0 {
+		threshold = defaultSceneThreshold
+	}
+
+	pipeline := r.analysisPipeline()
+	r.logger.Printf("🎞️  Scene change trigger on %s stream (threshold %g)", pipeline, threshold)
+	go r.keepStreamAlive(ctx, pipeline)
+
+	// The metadata filter prints the score of each frame select lets through
+	outputArgs := []string{
+		"-an",
+		"-vf", fmt.Sprintf("fps=%d,scale=%d:%d,select='gt(scene,%g)',metadata=print:key=lavfi.scene_score:file='pipe:1'",
+			analysisFPS, motion.Width, motion.Height, threshold),
+		"-f", "null",
+		"-",
+	}
+
+	for {
+		err := r.analyzeVideo(ctx, r.segmenters[pipeline], "SCENE", outputArgs, func(output io.Reader) {
+			scanner := bufio.NewScanner(output)
+			for scanner.Scan() {
+				value, ok := strings.CutPrefix(scanner.Text(), "lavfi.scene_score=")
+				if !ok {
+					continue
+				}
+				if score, err := strconv.ParseFloat(value, 64); err == nil && score > threshold {
+					r.trigger(fmt.Sprintf("scene change %.2f", score))
+				}
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.logger.Printf("Scene change trigger stopped: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(analysisRetryDelay):
+		}
+	}
+}
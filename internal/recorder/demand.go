@@ -0,0 +1,203 @@
+package recorder
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+)
+
+const (
+	// liveSegmentDuration is the target length of live HLS segments
+	liveSegmentDuration = 2 * time.Second
+
+	// livePlaylistSize is how many segments the live playlist lists
+	livePlaylistSize = 5
+
+	// livePartDuration is the target length of Low-Latency HLS parts.
+	// Players stay three parts behind the live edge, about 1s.
+	livePartDuration = 300 * time.Millisecond
+)
+
+// viewerWindow is how recently a client must have fetched a stream to count
+// as watching it. Players reload the live playlist every segment (2s).
+const viewerWindow = 10 * time.Second
+
+// liveIdleTimeout stops live pipelines nobody has fetched for this long.
+// Zero keeps them running all the time.
+var liveIdleTimeout time.Duration
+
+// SetLiveIdleTimeout makes live and preview streams start on the first
+// request and stop after timeout without one. Zero or less keeps them running
+// all the time. It must be called before any recorder is started.
+func SetLiveIdleTimeout(timeout time.Duration) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	liveIdleTimeout = timeout
+}
+
+// lowLatencyLive serves live streams as Low-Latency HLS
+var lowLatencyLive bool
+
+// SetLowLatencyLive makes live and preview playlists list partial segments,
+// with preload hints and blocking reloads, so players can stay about a second
+// behind the camera. It must be called before any recorder is created.
+func SetLowLatencyLive(enabled bool) {
+	lowLatencyLive = enabled
+}
+
+// demand tracks the clients fetching one of a camera's live streams
+type demand struct {
+	mu        sync.Mutex
+	viewers   map[string]time.Time // Client -> last fetch
+	lastFetch time.Time
+	wake      chan struct{} // Signals the on-demand runner that a client wants the stream
+}
+
+// newDemands creates demand tracking for the live pipelines
+func newDemands() map[string]*demand {
+	demands := make(map[string]*demand)
+	for _, name := range []string{PipelineLive, PipelinePreview} {
+		demands[name] = &demand{
+			viewers: make(map[string]time.Time),
+			wake:    make(chan struct{}, 1),
+		}
+	}
+	return demands
+}
+
+// newSegmenters creates the in-memory HLS segmenters of the live pipelines
+func newSegmenters() map[string]*hls.Segmenter {
+	var partTarget time.Duration
+	if lowLatencyLive {
+		partTarget = livePartDuration
+	}
+	return map[string]*hls.Segmenter{
+		PipelineLive:    hls.NewSegmenter(liveSegmentDuration, partTarget, livePlaylistSize),
+		PipelinePreview: hls.NewSegmenter(liveSegmentDuration, partTarget, livePlaylistSize),
+	}
+}
+
+// Segmenter returns the in-memory HLS segments of a live pipeline
+func (r *Recorder) Segmenter(pipeline string) (*hls.Segmenter, bool) {
+	segmenter, ok := r.segmenters[pipeline]
+	return segmenter, ok
+}
+
+// RequestStream records that client fetched a live pipeline's playlist or
+// segments, starting the pipeline if it runs on demand and is idle. An empty
+// client keeps the pipeline running without counting as a viewer.
+func (r *Recorder) RequestStream(pipeline, client string) {
+	d, ok := r.demands[pipeline]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	d.mu.Lock()
+	if client != "" {
+		d.viewers[client] = now
+	}
+	d.lastFetch = now
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Viewers returns how many clients are currently watching a live pipeline
+func (r *Recorder) Viewers(pipeline string) int {
+	d, ok := r.demands[pipeline]
+	if !ok {
+		return 0
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for client, seen := range d.viewers {
+		if time.Since(seen) > viewerWindow {
+			delete(d.viewers, client)
+		}
+	}
+	return len(d.viewers)
+}
+
+// idleFor returns how long ago the stream was last fetched
+func (d *demand) idleFor() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return time.Since(d.lastFetch)
+}
+
+// runLive runs the live stream, on demand if an idle timeout is set
+func (r *Recorder) runLive(ctx context.Context) {
+	if liveIdleTimeout > 0 {
+		r.runOnDemand(ctx, PipelineLive, "live", r.startLiveStream)
+		return
+	}
+	r.startLiveStream(ctx)
+}
+
+// runPreview runs the preview stream, on demand if an idle timeout is set
+func (r *Recorder) runPreview(ctx context.Context) {
+	if liveIdleTimeout > 0 {
+		r.runOnDemand(ctx, PipelinePreview, "preview", r.startPreviewStream)
+		return
+	}
+	r.startPreviewStream(ctx)
+}
+
+// runOnDemand starts an HLS pipeline when a client requests it and stops it
+// once nobody has fetched it for liveIdleTimeout
+func (r *Recorder) runOnDemand(ctx context.Context, pipeline, dirName string, run func(context.Context)) {
+	d := r.demands[pipeline]
+
+	for {
+		r.setState(StateIdle, pipeline)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		}
+		if d.idleFor() > liveIdleTimeout {
+			// Left over from a request during the previous run
+			continue
+		}
+
+		r.logger.Printf("👀 %s stream requested, starting", dirName)
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			run(runCtx)
+		}()
+
+		ticker := time.NewTicker(time.Second)
+	watch:
+		for d.idleFor() <= liveIdleTimeout {
+			select {
+			case <-ctx.Done():
+				break watch
+			case <-ticker.C:
+			}
+		}
+		ticker.Stop()
+
+		cancel()
+		<-done
+		if ctx.Err() != nil {
+			return
+		}
+
+		r.logger.Printf("💤 No %s viewers for %v, stopping stream", dirName, liveIdleTimeout)
+
+		// Old segments would show the next viewer a stale picture
+		r.segmenters[pipeline].Clear()
+	}
+}
@@ -0,0 +1,82 @@
+package recorder
+
+import (
+	"strings"
+)
+
+// FailureReason classifies why an FFmpeg pipeline is failing
+type FailureReason string
+
+const (
+	FailureNone              FailureReason = ""
+	FailureAuth              FailureReason = "auth_failed"
+	FailureConnectionRefused FailureReason = "connection_refused"
+	FailureTimeout           FailureReason = "timeout"
+	FailureUnreachable       FailureReason = "host_unreachable"
+	FailureStreamNotFound    FailureReason = "stream_not_found"
+	FailureUnsupportedCodec  FailureReason = "unsupported_codec"
+	FailureDiskFull          FailureReason = "disk_full"
+	FailureInvalidData       FailureReason = "invalid_data"
+)
+
+// ffmpegErrorPatterns maps lowercase substrings of FFmpeg log lines to
+// failure reasons. Order matters: the first match wins.
+var ffmpegErrorPatterns = []struct {
+	pattern string
+	reason  FailureReason
+}{
+	{"401 unauthorized", FailureAuth},
+	{"unauthorized", FailureAuth},
+	{"403 forbidden", FailureAuth},
+	{"connection refused", FailureConnectionRefused},
+	{"connection timed out", FailureTimeout},
+	{"operation timed out", FailureTimeout},
+	{"timed out", FailureTimeout},
+	{"no route to host", FailureUnreachable},
+	{"network is unreachable", FailureUnreachable},
+	{"host is unreachable", FailureUnreachable},
+	{"404 not found", FailureStreamNotFound},
+	{"454 session not found", FailureStreamNotFound},
+	{"stream not found", FailureStreamNotFound},
+	{"no space left on device", FailureDiskFull},
+	{"codec not currently supported", FailureUnsupportedCodec},
+	{"unsupported codec", FailureUnsupportedCodec},
+	{"decoder not found", FailureUnsupportedCodec},
+	{"invalid data found when processing input", FailureInvalidData},
+}
+
+// ClassifyFFmpegError maps an FFmpeg stderr line to a failure reason, or
+// FailureNone if the line isn't a recognized error
+func ClassifyFFmpegError(line string) FailureReason {
+	lower := strings.ToLower(line)
+	for _, p := range ffmpegErrorPatterns {
+		if strings.Contains(lower, p.pattern) {
+			return p.reason
+		}
+	}
+	return FailureNone
+}
+
+// Description returns an operator-friendly explanation of the failure
+func (f FailureReason) Description() string {
+	switch f {
+	case FailureAuth:
+		return "Authentication failed - check the camera username/password"
+	case FailureConnectionRefused:
+		return "Connection refused - camera is up but not accepting RTSP connections"
+	case FailureTimeout:
+		return "Connection timed out - camera may be offline"
+	case FailureUnreachable:
+		return "Camera unreachable - check network and power"
+	case FailureStreamNotFound:
+		return "Stream not found - check the RTSP path"
+	case FailureUnsupportedCodec:
+		return "Unsupported codec - camera stream can't be copied into the output"
+	case FailureDiskFull:
+		return "Disk full - recordings can't be written"
+	case FailureInvalidData:
+		return "Invalid data from camera stream"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,109 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// muxerOption is a single FFmpeg muxer option (name without the leading dash)
+type muxerOption struct {
+	name  string
+	value string
+}
+
+// muxerArgs turns muxer options into FFmpeg command line arguments
+func muxerArgs(options []muxerOption) []string {
+	args := make([]string, 0, len(options)*2)
+	for _, opt := range options {
+		args = append(args, "-"+opt.name, opt.value)
+	}
+	return args
+}
+
+// teeOutput formats one output of FFmpeg's tee muxer: [f=format:opt=val]target
+func teeOutput(format string, options []muxerOption, target string) string {
+	// FFmpeg unescapes option values twice: once when splitting the tee
+	// argument into outputs on '|', and again when parsing the bracketed options
+	optionEscape := strings.NewReplacer(`\`, `\\`, `:`, `\:`, `=`, `\=`, `'`, `\'`)
+	outputEscape := strings.NewReplacer(`\`, `\\`, `|`, `\|`, `'`, `\'`)
+
+	parts := []string{"f=" + format}
+	for _, opt := range options {
+		parts = append(parts, opt.name+"="+optionEscape.Replace(opt.value))
+	}
+	return outputEscape.Replace("[" + strings.Join(parts, ":") + "]" + target)
+}
+
+// startIngest runs the single-connection pipeline, restarting it on failure.
+// Recording and live streaming share this retry loop since they share one
+// FFmpeg process.
+func (r *Recorder) startIngest(ctx context.Context) {
+	r.runPipeline(ctx, "Ingest", r.ingest, PipelineRecord, PipelineLive)
+}
+
+// ingest pulls the camera stream once and uses FFmpeg's tee muxer to write
+// both the recording segments and the live HLS stream
+func (r *Recorder) ingest(ctx context.Context) error {
+	outputPattern, err := r.prepareRecordingDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The live output goes to the in-memory segmenter through a pipe. It's
+	// the second extra file after the progress pipe, so FFmpeg sees fd 4.
+	segmenter := r.segmenters[PipelineLive]
+	segmenter.Discontinue()
+
+	liveReader, liveWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("creating live pipe: %w", err)
+	}
+	defer liveWriter.Close()
+	go func() {
+		defer liveReader.Close()
+		io.Copy(segmenter, liveReader)
+	}()
+
+	outputs := []string{
+		teeOutput("segment", r.segmentOptions(), outputPattern),
+		teeOutput("mpegts", liveMuxerOptions(), "pipe:4"),
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+
+		// Input options for low latency (recording is unaffected)
+		"-fflags", "nobuffer",
+		"-flags", "low_delay",
+		"-rtsp_transport", "tcp",
+		"-i", r.camera.RecordStream(),
+
+		// Tee needs explicit stream mapping; audio is optional
+		"-map", "0:v",
+		"-map", "0:a?",
+		"-c:v", "copy",
+		"-c:a", r.recordAudioCodec(),
+	}
+	args = append(args, progressArgs...)
+	args = append(args, "-f", "tee", strings.Join(outputs, "|"))
+
+	// The ingest process is tracked as the recording process so Stop handles it
+	r.recordCmd = exec.CommandContext(ctx, "ffmpeg", args...)
+	r.recordCmd.Stderr = r.stderrWriter("INGEST", PipelineRecord, PipelineLive)
+	r.recordCmd.ExtraFiles = []*os.File{liveWriter}
+
+	// Live view shares the process, so its status follows the live segments
+	r.setState(StateConnecting, PipelineLive)
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	go r.awaitSegment(runCtx, segmenter, PipelineLive)
+
+	return r.runRecordingCmd(ctx, r.recordCmd,
+		"📡 Ingest started (recording + live stream from one connection)",
+		PipelineRecord, PipelineLive)
+}
@@ -0,0 +1,165 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/detect"
+	"github.com/mmuteeullah/CoreNVR/internal/events"
+)
+
+const (
+	// objectInterval is how often a frame is sent to the object detector
+	// while motion lasts
+	objectInterval = 2 * time.Second
+
+	// objectMaxFrames is how many frames of one motion event are sent, so
+	// long events don't keep the detector busy
+	objectMaxFrames = 5
+
+	// defaultMinConfidence is the confidence below which detections are
+	// ignored
+	defaultMinConfidence = 0.5
+
+	// objectFrameTimeout is how long to wait for a keyframe to send
+	objectFrameTimeout = 10 * time.Second
+)
+
+// objectDetector finds objects in motion frames
+var objectDetector detect.Detector
+
+// SetObjectDetector sets the detector that cameras with object detection
+// send motion frames to. It must be called before any recorder is started.
+func SetObjectDetector(d detect.Detector) {
+	objectDetector = d
+}
+
+// objectsEnabled reports whether motion frames go to the object detector
+func (r *Recorder) objectsEnabled() bool {
+	return objectDetector != nil && r.camera.Objects != nil && r.camera.Objects.Enabled
+}
+
+// detectObjects sends frames to the object detector until motionEnded is
+// closed or enough frames were sent, and adds what it finds to the motion
+// event. The first detection matching the camera's labels is notified.
+func (r *Recorder) detectObjects(ctx context.Context, event *events.Event, motionEnded <-chan struct{}) {
+	minConfidence := r.camera.Objects.MinConfidence
+	if minConfidence == 0 {
+		minConfidence = defaultMinConfidence
+	}
+
+	alerted := false
+	for i := 0; i < objectMaxFrames; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-motionEnded:
+				return
+			case <-time.After(objectInterval):
+			}
+		}
+
+		frame, err := r.objectFrame(ctx)
+		if err != nil {
+			r.logger.Printf("Object detection skipped a frame: %v", err)
+			continue
+		}
+		found, err := objectDetector.Detect(ctx, frame)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Printf("Object detection failed: %v", err)
+			}
+			continue
+		}
+
+		var confident []detect.Detection
+		for _, d := range found {
+			if d.Confidence >= minConfidence {
+				confident = append(confident, d)
+			}
+		}
+		if len(confident) == 0 {
+			continue
+		}
+		r.logger.Printf("🔎 Detected %s", describeObjects(confident))
+		r.addObjects(event, confident)
+
+		if alerted {
+			continue
+		}
+		if matches := r.alertObjects(confident); len(matches) > 0 {
+			alerted = true
+			if notify != nil {
+				notify(fmt.Sprintf("Camera %s detected %s at %s", r.camera.Name, describeObjects(matches), time.Now().Format("15:04:05")))
+			}
+		}
+	}
+}
+
+// objectFrame decodes the next keyframe of the stream motion detection
+// watches to a JPEG, so the detector sees the scene as it moves. Analysis
+// keeps that stream running, so no extra camera connection is needed.
+func (r *Recorder) objectFrame(ctx context.Context) ([]byte, error) {
+	source := r.segmenters[r.analysisPipeline()]
+	frames, unsubscribe := source.Subscribe(1)
+	defer unsubscribe()
+
+	select {
+	case frame, ok := <-frames:
+		if !ok {
+			return nil, errors.New("stream stopped")
+		}
+		return r.decodeKeyframe(ctx, frame, source.VideoCodec())
+	case <-time.After(objectFrameTimeout):
+		return nil, fmt.Errorf("no keyframe for %v", objectFrameTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// addObjects adds detections to a motion event and stores it
+func (r *Recorder) addObjects(event *events.Event, detections []detect.Detection) {
+	r.motionMu.Lock()
+	defer r.motionMu.Unlock()
+
+	event.Objects = detect.Merge(event.Objects, detections)
+	if eventStore != nil && event.ID != "" {
+		if err := eventStore.Update(*event); err != nil {
+			r.logger.Printf("Failed to store motion event: %v", err)
+		}
+	}
+}
+
+// alertObjects returns the detections whose labels the camera alerts on,
+// all of them if it has no label filter
+func (r *Recorder) alertObjects(detections []detect.Detection) []detect.Detection {
+	labels := r.camera.Objects.Labels
+	if len(labels) == 0 {
+		return detections
+	}
+
+	var matches []detect.Detection
+	for _, d := range detections {
+		for _, label := range labels {
+			if strings.EqualFold(d.Label, label) {
+				matches = append(matches, d)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// describeObjects lists detections for logs and notifications, such as
+// "person (92%), car (80%)"
+func describeObjects(detections []detect.Detection) string {
+	parts := make([]string, len(detections))
+	for i, d := range detections {
+		parts[i] = fmt.Sprintf("%s (%.0f%%)", d.Label, d.Confidence*100)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,209 @@
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pauseFile holds a camera's pause state in its storage folder, so a pause
+// survives restarts
+const pauseFile = "pause.json"
+
+// PausePeriod is a span of time a camera's recording was paused
+type PausePeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// PauseStatus describes a camera's current manual pause
+type PauseStatus struct {
+	Since    time.Time `json:"since"`
+	ResumeAt time.Time `json:"resume_at,omitempty"` // Zero if paused until resumed
+}
+
+// pauseState is the saved pause state of a camera
+type pauseState struct {
+	Paused   bool          `json:"paused"`
+	Since    time.Time     `json:"since,omitempty"`
+	ResumeAt time.Time     `json:"resume_at,omitempty"`
+	History  []PausePeriod `json:"history"` // Finished pauses, kept for the timeline
+}
+
+// Pause stops recording until Resume is called, or for duration if it's
+// positive. Live view keeps running. Pausing a paused camera changes when
+// it resumes.
+func (r *Recorder) Pause(duration time.Duration) error {
+	r.statusMu.Lock()
+	state := r.pause
+	now := time.Now()
+	if !state.Paused {
+		state.Paused = true
+		state.Since = now
+	}
+	state.ResumeAt = time.Time{}
+	if duration > 0 {
+		state.ResumeAt = now.Add(duration)
+	}
+
+	err := r.savePauseState(state)
+	if err == nil {
+		r.pause = state
+	}
+	r.statusMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if state.ResumeAt.IsZero() {
+		r.logger.Println("⏸️  Recording paused until resumed")
+	} else {
+		r.logger.Printf("⏸️  Recording paused until %s", state.ResumeAt.Format("2006-01-02 15:04:05"))
+	}
+	r.wakeRecordWindow()
+	return nil
+}
+
+// Resume ends a manual pause
+func (r *Recorder) Resume() error {
+	r.statusMu.Lock()
+	state := r.pause
+	if !state.Paused {
+		r.statusMu.Unlock()
+		return nil
+	}
+
+	// An auto-resume that came due while the service was down ends there
+	end := time.Now()
+	if !state.ResumeAt.IsZero() && state.ResumeAt.Before(end) {
+		end = state.ResumeAt
+	}
+
+	state.History = append(pruneHistory(state.History, r.storage.RetentionDays), PausePeriod{Start: state.Since, End: end})
+	state.Paused = false
+	state.Since = time.Time{}
+	state.ResumeAt = time.Time{}
+
+	err := r.savePauseState(state)
+	if err == nil {
+		r.pause = state
+	}
+	r.statusMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	r.logger.Println("▶️  Recording resumed")
+	r.wakeRecordWindow()
+	return nil
+}
+
+// PauseStatus returns the camera's current pause, if it is paused
+func (r *Recorder) PauseStatus() (PauseStatus, bool) {
+	r.statusMu.RLock()
+	defer r.statusMu.RUnlock()
+
+	if !r.pause.Paused {
+		return PauseStatus{}, false
+	}
+	return PauseStatus{Since: r.pause.Since, ResumeAt: r.pause.ResumeAt}, true
+}
+
+// PausedPeriods returns when a camera's recording was paused between from
+// and to, including a pause still in progress
+func PausedPeriods(basePath, camera string, from, to time.Time) []PausePeriod {
+	state, err := loadPauseState(basePath, camera)
+	if err != nil {
+		return nil
+	}
+
+	periods := state.History
+	if state.Paused {
+		end := time.Now()
+		if !state.ResumeAt.IsZero() && state.ResumeAt.Before(end) {
+			end = state.ResumeAt
+		}
+		periods = append(periods, PausePeriod{Start: state.Since, End: end})
+	}
+
+	var result []PausePeriod
+	for _, p := range periods {
+		if !p.End.After(from) || !p.Start.Before(to) {
+			continue
+		}
+		if p.Start.Before(from) {
+			p.Start = from
+		}
+		if p.End.After(to) {
+			p.End = to
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// loadPauseState reads a camera's saved pause state. A camera that was never
+// paused has none.
+func loadPauseState(basePath, camera string) (pauseState, error) {
+	data, err := os.ReadFile(filepath.Join(basePath, camera, pauseFile))
+	if os.IsNotExist(err) {
+		return pauseState{}, nil
+	}
+	if err != nil {
+		return pauseState{}, fmt.Errorf("reading pause state: %w", err)
+	}
+
+	var state pauseState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return pauseState{}, fmt.Errorf("decoding pause state: %w", err)
+	}
+	return state, nil
+}
+
+// savePauseState writes the camera's pause state atomically
+func (r *Recorder) savePauseState(state pauseState) error {
+	if state.History == nil {
+		state.History = []PausePeriod{}
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encoding pause state: %w", err)
+	}
+
+	dir := filepath.Join(r.storage.BasePath, r.camera.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating camera directory: %w", err)
+	}
+
+	path := filepath.Join(dir, pauseFile)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("writing pause state: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("replacing pause state: %w", err)
+	}
+
+	return nil
+}
+
+// pruneHistory drops pauses that ended before the oldest recordings kept
+func pruneHistory(history []PausePeriod, retentionDays int) []PausePeriod {
+	if retentionDays <= 0 {
+		return history
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -retentionDays-1)
+	kept := history[:0:0]
+	for _, p := range history {
+		if p.End.After(cutoff) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
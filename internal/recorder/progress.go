@@ -0,0 +1,223 @@
+package recorder
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// stallTimeout is how long a running pipeline may go without new output
+	// before it is considered frozen and killed
+	stallTimeout = 10 * time.Second
+
+	// startupTimeout is how long FFmpeg may take to connect and produce its
+	// first output
+	startupTimeout = 30 * time.Second
+)
+
+// progressArgs makes FFmpeg write -progress reports to the pipe passed as its
+// first extra file (fd 3)
+var progressArgs = []string{"-progress", "pipe:3"}
+
+// Progress is the latest -progress report of a running FFmpeg pipeline
+type Progress struct {
+	Frame       int64     `json:"frame"`
+	FPS         float64   `json:"fps"`
+	BitrateKbps float64   `json:"bitrate_kbps"`
+	TotalSize   int64     `json:"total_size"`
+	OutTime     float64   `json:"out_time"` // Seconds of output written
+	Speed       string    `json:"speed,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	LastFrameAt time.Time `json:"last_frame_at"` // When output last advanced
+}
+
+// advancedFrom reports whether FFmpeg produced new output since prev
+func (p Progress) advancedFrom(prev *Progress) bool {
+	if prev == nil {
+		return p.Frame > 0 || p.TotalSize > 0 || p.OutTime > 0
+	}
+	return p.Frame > prev.Frame || p.TotalSize > prev.TotalSize || p.OutTime > prev.OutTime
+}
+
+// progressMonitor reads an FFmpeg process's -progress reports, publishes them
+// on pipeline status and kills the process when its output freezes
+type progressMonitor struct {
+	r         *Recorder
+	cmd       *exec.Cmd
+	pipelines []string
+	reader    *os.File
+	writer    *os.File
+	done      chan struct{}
+	release   func() // Frees the startup slot once FFmpeg produces output
+
+	mu       sync.Mutex
+	last     *Progress
+	stalled  bool
+	stallMsg string
+}
+
+// monitorProgress attaches a progress pipe to cmd. The command's arguments
+// must include progressArgs. The pipe becomes the first extra file (fd 3),
+// moving any other extra files up by one.
+// It waits for a process-wide startup slot, which is held until FFmpeg
+// produces output or exits.
+func (r *Recorder) monitorProgress(ctx context.Context, cmd *exec.Cmd, pipelines ...string) (*progressMonitor, error) {
+	release, err := acquireStartSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("creating progress pipe: %w", err)
+	}
+
+	cmd.ExtraFiles = append([]*os.File{writer}, cmd.ExtraFiles...)
+
+	return &progressMonitor{
+		r:         r,
+		cmd:       cmd,
+		pipelines: pipelines,
+		reader:    reader,
+		writer:    writer,
+		done:      make(chan struct{}),
+		release:   release,
+	}, nil
+}
+
+// start begins reading progress once the process has started
+func (m *progressMonitor) start(ctx context.Context) {
+	// Only FFmpeg keeps the write end open, so reads end when it exits
+	m.writer.Close()
+
+	go func() {
+		defer close(m.done)
+		defer m.reader.Close()
+		m.read()
+	}()
+	go m.watch(ctx)
+}
+
+// close releases the pipe when the process failed to start
+func (m *progressMonitor) close() {
+	m.writer.Close()
+	m.reader.Close()
+	m.release()
+}
+
+// result turns FFmpeg's exit error into a stall error if the monitor killed it
+func (m *progressMonitor) result(waitErr error) error {
+	m.release()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stalled {
+		return fmt.Errorf("pipeline stalled: %s", m.stallMsg)
+	}
+	return waitErr
+}
+
+// read parses key=value progress blocks, each terminated by a progress= line
+func (m *progressMonitor) read() {
+	var current Progress
+	scanner := bufio.NewScanner(m.reader)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "frame":
+			current.Frame, _ = strconv.ParseInt(value, 10, 64)
+		case "fps":
+			current.FPS, _ = strconv.ParseFloat(value, 64)
+		case "bitrate":
+			current.BitrateKbps, _ = strconv.ParseFloat(strings.TrimSuffix(value, "kbits/s"), 64)
+		case "total_size":
+			current.TotalSize, _ = strconv.ParseInt(value, 10, 64)
+		case "out_time_us":
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil {
+				current.OutTime = float64(us) / 1e6
+			}
+		case "speed":
+			current.Speed = strings.TrimSpace(value)
+		case "progress":
+			m.update(current)
+			current = Progress{}
+		}
+	}
+}
+
+// update publishes a complete progress report
+func (m *progressMonitor) update(p Progress) {
+	now := time.Now()
+	p.UpdatedAt = now
+
+	m.mu.Lock()
+	if p.advancedFrom(m.last) {
+		p.LastFrameAt = now
+	} else if m.last != nil {
+		p.LastFrameAt = m.last.LastFrameAt
+	}
+	m.last = &p
+	m.mu.Unlock()
+
+	// Connected: let the next pipeline start
+	if !p.LastFrameAt.IsZero() {
+		m.release()
+	}
+
+	m.r.setProgress(&p, m.pipelines...)
+}
+
+// watch kills the process if it produces no output within startupTimeout, or
+// stops producing output for stallTimeout
+func (m *progressMonitor) watch(ctx context.Context) {
+	started := time.Now()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+
+		m.mu.Lock()
+		var msg string
+		switch {
+		case m.last == nil || m.last.LastFrameAt.IsZero():
+			if time.Since(started) > startupTimeout {
+				msg = fmt.Sprintf("no output within %v of starting", startupTimeout)
+			}
+		case time.Since(m.last.LastFrameAt) > stallTimeout:
+			msg = fmt.Sprintf("no new output for %v", time.Since(m.last.LastFrameAt).Round(time.Second))
+		}
+		if msg != "" {
+			m.stalled = true
+			m.stallMsg = msg
+		}
+		m.mu.Unlock()
+
+		if msg != "" {
+			m.r.logger.Printf("🧊 %s pipeline frozen (%s), killing FFmpeg", strings.Join(m.pipelines, "+"), msg)
+			if m.cmd.Process != nil {
+				m.cmd.Process.Kill()
+			}
+			return
+		}
+	}
+}
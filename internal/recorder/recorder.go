@@ -1,120 +1,240 @@
 package recorder
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/mmuteeullah/CoreNVR/internal/catalog"
 	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/events"
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+	"github.com/mmuteeullah/CoreNVR/internal/tamper"
 )
 
 // Recorder handles recording for a single camera
 type Recorder struct {
 	camera  config.CameraConfig
 	storage config.StorageConfig
+	catalog *catalog.Catalog
 	logger  *log.Logger
-	recordCmd     *exec.Cmd  // For long-term recording (30-min segments)
+	recordCmd     *exec.Cmd  // For long-term recording (storage.segment_duration segments)
 	liveStreamCmd *exec.Cmd  // For live streaming (2-sec segments)
+	previewCmd    *exec.Cmd  // For low-resolution grid previews (optional)
 	enableLive    bool       // Whether to enable live streaming
 	ctx           context.Context
 	cancel        context.CancelFunc
 	lastSegmentTime time.Time  // Track last recording time
+	pipelines     map[string]*PipelineStatus // Observable state per pipeline
+	recordOff     bool       // Recording is scheduled off or paused
+	recordOnAt    time.Time  // When recording was last switched back on
+	pause         pauseState // Manual pause, saved in the camera's storage folder
+	recordWake    chan struct{} // Wakes the record window when the pause changes
+	demands       map[string]*demand // Viewers of the live and preview streams
+	segmenters    map[string]*hls.Segmenter // In-memory HLS of the live and preview streams
+	statusMu      sync.RWMutex
+
+	// Latest snapshot, guarded by statusMu; snapshotGrab serializes taking them
+	snapshot     []byte
+	snapshotAt   time.Time
+	snapshotGrab sync.Mutex
+
+	// Event recording: the rolling buffer, and until when the latest trigger
+	// keeps a clip going, guarded by statusMu
+	eventBuffer   *hls.Segmenter
+	clipUntil     time.Time
+	triggerReason string
+	clipWake      chan struct{}
+
+	// Tamper state and its open event, guarded by statusMu; tamperMu
+	// serializes changes to them
+	tamper      tamper.State
+	tamperEvent *events.Event
+	tamperMu    sync.Mutex
+
+	// Guards the open motion event, which object detection adds to
+	motionMu sync.Mutex
+
+	// Goroutines started by Start, which waits for them before returning
+	wg sync.WaitGroup
 }
 
 // New creates a new Recorder instance
-func New(camera config.CameraConfig, storage config.StorageConfig) *Recorder {
+func New(camera config.CameraConfig, storage config.StorageConfig, cat *catalog.Catalog) *Recorder {
 	logger := log.New(os.Stdout, fmt.Sprintf("[%s] ", camera.Name), log.LstdFlags)
 
-	return &Recorder{
+	// A pause outlives restarts of the recorder and the service
+	pause, err := loadPauseState(storage.BasePath, camera.Name)
+	if err != nil {
+		logger.Printf("WARNING: %v", err)
+	}
+
+	r := &Recorder{
 		camera:     camera,
 		storage:    storage,
+		catalog:    cat,
 		logger:     logger,
 		enableLive: true,  // Enable live streaming by default
+		pipelines:  newPipelineStatuses(),
+		pause:      pause,
+		recordWake: make(chan struct{}, 1),
+		demands:    newDemands(),
+		segmenters: newSegmenters(),
 	}
+
+	if camera.RecordingMode() == config.RecordEvents {
+		r.eventBuffer = newEventBuffer(camera)
+		r.clipWake = make(chan struct{}, 1)
+	}
+	return r
 }
 
-// Start begins recording from the camera
+// Start begins recording from the camera. It returns once the context is
+// cancelled and everything it started has stopped.
 func (r *Recorder) Start(ctx context.Context) {
 	// Create internal context for this recorder instance
 	r.ctx, r.cancel = context.WithCancel(ctx)
 
-	// Start recording stream (30-minute segments for storage)
-	go r.startRecording(r.ctx)
-
-	// Start live stream (2-second segments for web UI) if enabled
+	r.setState(StateStarting, PipelineRecord)
 	if r.enableLive {
-		go r.startLiveStream(r.ctx)
+		r.setState(StateStarting, PipelineLive)
+	}
+
+	if r.camera.SingleIngest && r.enableLive {
+		// One camera connection feeds both recording and live stream.
+		// While recording is off, live view runs on its own.
+		r.spawn(func(ctx context.Context) { r.runRecordWindow(ctx, r.startIngest, r.runLive) })
+	} else {
+		// Start recording stream (segment_duration segments for storage)
+		// whenever the schedule and pause allow. In events mode only clips
+		// around triggers are stored.
+		record := r.startRecording
+		if r.eventBuffer != nil {
+			record = r.startEventRecording
+		}
+		r.spawn(func(ctx context.Context) { r.runRecordWindow(ctx, record, nil) })
+
+		// Start live stream (2-second segments for web UI) if enabled
+		if r.enableLive {
+			r.spawn(r.runLive)
+		}
+	}
+
+	// Start low-resolution preview stream for grid tiles if configured
+	if r.enableLive && r.camera.PreviewURL != "" {
+		r.setState(StateStarting, PipelinePreview)
+		r.spawn(r.runPreview)
+	}
+
+	// Store periodic snapshots if configured
+	if r.camera.SnapshotInterval > 0 {
+		r.spawn(r.runSnapshots)
+	}
+
+	// Watch for motion and tampering if configured
+	motionEnabled := r.camera.Motion != nil && r.camera.Motion.Enabled
+	tamperEnabled := r.camera.Tamper != nil && r.camera.Tamper.Enabled
+	if r.enableLive && (motionEnabled || tamperEnabled) {
+		r.spawn(r.runAnalysis)
+	}
+
+	// Listen for loud noises if configured
+	if r.enableLive && r.camera.Audio != nil && r.camera.Audio.Enabled {
+		r.spawn(r.runAudio)
 	}
 
 	// Wait for context cancellation
 	<-r.ctx.Done()
 	r.logger.Println("Shutting down recorder")
+
+	// Pipelines finalize their segments on the way out, which must be done
+	// before another recorder for this camera starts
+	r.wg.Wait()
+}
+
+// spawn runs fn with the recorder's context in a goroutine that Start waits
+// for
+func (r *Recorder) spawn(fn func(ctx context.Context)) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		fn(r.ctx)
+	}()
 }
 
 // startRecording handles long-term recording with large segments
 func (r *Recorder) startRecording(ctx context.Context) {
+	r.runPipeline(ctx, "Recording", r.record, PipelineRecord)
+}
+
+// startLiveStream handles low-latency streaming for web UI
+func (r *Recorder) startLiveStream(ctx context.Context) {
+	r.runPipeline(ctx, "Live stream", r.liveStream, PipelineLive)
+}
+
+// startPreviewStream handles the low-resolution stream for grid tiles
+func (r *Recorder) startPreviewStream(ctx context.Context) {
+	r.runPipeline(ctx, "Preview stream", r.previewStream, PipelinePreview)
+}
+
+// runPipeline runs an FFmpeg pipeline, retrying on failure until the
+// context is cancelled or max retries is reached. The named pipelines'
+// status follows the process.
+func (r *Recorder) runPipeline(ctx context.Context, name string, run func(context.Context) error, pipelines ...string) {
 	retryCount := 0
+	retries := newBackoff(r.camera)
 
 	for {
 		select {
 		case <-ctx.Done():
+			r.setState(StateStopped, pipelines...)
 			return
 		default:
 			// Check max retries
 			if r.camera.MaxRetries >= 0 && retryCount >= r.camera.MaxRetries {
-				r.logger.Printf("Recording: Max retries (%d) reached", r.camera.MaxRetries)
+				r.logger.Printf("%s: Max retries (%d) reached", name, r.camera.MaxRetries)
+				r.setState(StateFailed, pipelines...)
 				return
 			}
 
-			// Start recording
-			r.logger.Printf("Starting recording (attempt %d)", retryCount+1)
-			err := r.record(ctx)
+			r.logger.Printf("Starting %s (attempt %d)", strings.ToLower(name), retryCount+1)
+			startedAt := time.Now()
+			err := run(ctx)
 
-			if err != nil {
-				r.logger.Printf("Recording failed: %v", err)
-				retryCount++
-
-				// Wait before retry
-				select {
-				case <-ctx.Done():
-					return
-				case <-time.After(time.Duration(r.camera.RetryDelay) * time.Second):
-					continue
-				}
+			// A long run means the camera was fine; start backing off afresh
+			if retries.observe(time.Since(startedAt)) {
+				retryCount = 0
 			}
-		}
-	}
-}
 
-// startLiveStream handles low-latency streaming for web UI
-func (r *Recorder) startLiveStream(ctx context.Context) {
-	retryCount := 0
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			if r.camera.MaxRetries >= 0 && retryCount >= r.camera.MaxRetries {
-				r.logger.Printf("Live stream: Max retries (%d) reached", r.camera.MaxRetries)
+			// FFmpeg is killed on shutdown - that's not a failure
+			if ctx.Err() != nil {
+				r.setState(StateStopped, pipelines...)
 				return
 			}
 
-			r.logger.Printf("Starting live stream (attempt %d)", retryCount+1)
-			err := r.liveStream(ctx)
-
 			if err != nil {
-				r.logger.Printf("Live stream failed: %v", err)
+				r.logger.Printf("%s failed: %v", name, err)
 				retryCount++
+				r.setFailure(err, retryCount, pipelines...)
 
+				// Wait before retry
+				delay := retries.next()
+				r.logger.Printf("%s: Retrying in %v", name, delay.Round(100*time.Millisecond))
+				r.setState(StateBackoff, pipelines...)
 				select {
 				case <-ctx.Done():
+					r.setState(StateStopped, pipelines...)
 					return
-				case <-time.After(time.Duration(r.camera.RetryDelay) * time.Second):
+				case <-time.After(delay):
 					continue
 				}
 			}
@@ -124,10 +244,41 @@ func (r *Recorder) startLiveStream(ctx context.Context) {
 
 // record handles the actual FFmpeg recording
 func (r *Recorder) record(ctx context.Context) error {
+	outputPattern, err := r.prepareRecordingDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	// FFmpeg arguments for RECORDING (segment_duration segments)
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-rtsp_transport", "tcp",
+		"-i", r.camera.RecordStream(),
+		"-c:v", "copy",             // No video transcoding
+		"-c:a", r.recordAudioCodec(),
+	}
+	args = append(args, progressArgs...)
+	args = append(args, "-f", "segment")
+	args = append(args, muxerArgs(r.segmentOptions())...)
+	args = append(args, outputPattern)
+
+	// Create command with context for proper cancellation
+	r.recordCmd = exec.CommandContext(ctx, "ffmpeg", args...)
+
+	// Log stderr for debugging
+	r.recordCmd.Stderr = r.stderrWriter("REC", PipelineRecord)
+
+	return r.runRecordingCmd(ctx, r.recordCmd, fmt.Sprintf("📹 Recording started (%d-sec segments)", r.storage.SegmentDuration), PipelineRecord)
+}
+
+// prepareRecordingDir creates the recordings folders and returns the FFmpeg
+// output pattern for segments
+func (r *Recorder) prepareRecordingDir(ctx context.Context) (string, error) {
 	// Create base recordings directory
 	baseDir := filepath.Join(r.storage.BasePath, r.camera.Name, "recordings")
 	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		return fmt.Errorf("creating base recordings directory: %w", err)
+		return "", fmt.Errorf("creating base recordings directory: %w", err)
 	}
 
 	// Create today's date folder
@@ -135,63 +286,261 @@ func (r *Recorder) record(ctx context.Context) error {
 	dateStr := time.Now().Format("2006-01-02")
 	dateDir := filepath.Join(baseDir, dateStr)
 	if err := os.MkdirAll(dateDir, 0755); err != nil {
-		return fmt.Errorf("creating date directory: %w", err)
+		return "", fmt.Errorf("creating date directory: %w", err)
 	}
 
 	// Start a goroutine to create tomorrow's folder at midnight
 	go r.createNextDayFolder(ctx, baseDir)
 
-	// Build FFmpeg command with strftime for FULL path (including date folder)
+	// Build FFmpeg output with strftime for FULL path (including date folder)
 	// This ensures recordings go into the correct date folder even after midnight
-	outputPattern := filepath.Join(baseDir, "%Y-%m-%d", "%H-%M-%S.ts")
+	return filepath.Join(baseDir, "%Y-%m-%d", "%H-%M-%S"+r.camera.SegmentExtension()), nil
+}
 
-	// FFmpeg arguments for RECORDING (30-minute segments)
-	args := []string{
-		"-hide_banner",
-		"-loglevel", "error",
-		"-rtsp_transport", "tcp",
-		"-i", r.camera.URL,
-		"-c:v", "copy",             // No video transcoding
-		"-c:a", "copy",             // No audio transcoding
-		"-f", "segment",
-		"-segment_time", "1800",    // 30-minute segments for storage
-		"-segment_format", "mpegts",
-		"-segment_atclocktime", "1",
-		"-reset_timestamps", "1",
-		"-strftime", "1",           // Enable strftime for date-based folders
-		outputPattern,
+// segmentOptions returns the segment muxer options for recording
+func (r *Recorder) segmentOptions() []muxerOption {
+	options := []muxerOption{
+		{"segment_time", strconv.Itoa(r.storage.SegmentDuration)},
 	}
 
-	// Create command with context for proper cancellation
-	r.recordCmd = exec.CommandContext(ctx, "ffmpeg", args...)
+	if r.camera.RecordContainer() == config.ContainerFMP4 {
+		// Fragmented MP4: playable while being written and after a crash
+		options = append(options,
+			muxerOption{"segment_format", "mp4"},
+			muxerOption{"segment_format_options", "movflags=" + catalog.FragmentedMP4Flags},
+		)
+	} else {
+		options = append(options, muxerOption{"segment_format", "mpegts"})
+	}
 
-	// Log stderr for debugging
-	r.recordCmd.Stderr = &logWriter{logger: r.logger, prefix: "REC"}
+	return append(options,
+		muxerOption{"segment_atclocktime", "1"},
+		muxerOption{"reset_timestamps", "1"},
+		muxerOption{"strftime", "1"},          // Enable strftime for date-based folders
+		muxerOption{"segment_list", "pipe:1"}, // Report each finished segment on stdout
+		muxerOption{"segment_list_type", "csv"},
+	)
+}
+
+// recordAudioCodec returns the audio codec for recordings. Audio is copied
+// except in fragmented MP4, which can't hold the G.711 audio many cameras send.
+func (r *Recorder) recordAudioCodec() string {
+	if r.camera.RecordContainer() == config.ContainerFMP4 {
+		return "aac"
+	}
+	return "copy"
+}
+
+// runRecordingCmd starts an FFmpeg process that writes recording segments and
+// keeps the catalog updated until it exits. pipelines are the pipelines the
+// process feeds, for progress reporting.
+func (r *Recorder) runRecordingCmd(ctx context.Context, cmd *exec.Cmd, startedMsg string, pipelines ...string) error {
+	// A segment left open by the previous FFmpeg run will never be reported
+	r.finalizeActiveSegment()
+
+	progress, err := r.monitorProgress(ctx, cmd, pipelines...)
+	if err != nil {
+		return err
+	}
+
+	segmentList, err := cmd.StdoutPipe()
+	if err != nil {
+		progress.close()
+		return fmt.Errorf("creating segment list pipe: %w", err)
+	}
 
 	// Start FFmpeg
-	if err := r.recordCmd.Start(); err != nil {
+	if err := cmd.Start(); err != nil {
+		progress.close()
 		return fmt.Errorf("starting recording ffmpeg: %w", err)
 	}
 
-	r.logger.Println("📹 Recording started (30-min segments)")
+	r.logger.Println(startedMsg)
+	r.setState(StateConnecting, PipelineRecord)
+	progress.start(ctx)
+
+	// Catalog segments as FFmpeg closes them
+	listDone := make(chan struct{})
+	go func() {
+		defer close(listDone)
+		r.watchSegmentList(ctx, segmentList)
+	}()
+	go r.trackActiveSegment(ctx)
 
 	// Wait for completion or context cancellation
-	return r.recordCmd.Wait()
+	<-listDone
+	err = progress.result(cmd.Wait())
+
+	r.finalizeActiveSegment()
+	return err
+}
+
+// watchSegmentList reads FFmpeg's CSV segment list (filename,start,end) and
+// adds each finished segment to the catalog
+func (r *Recorder) watchSegmentList(ctx context.Context, list io.Reader) {
+	scanner := bufio.NewScanner(list)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) < 3 {
+			continue
+		}
+
+		filename := filepath.Base(strings.Trim(fields[0], "\""))
+		date, ok := r.segmentDate(filename)
+		if !ok {
+			r.logger.Printf("Finished segment %s not found on disk", filename)
+			continue
+		}
+
+		seg, err := r.catalog.AddFile(r.camera.Name, date, filename)
+		if err != nil {
+			r.logger.Printf("Failed to catalog segment %s/%s: %v", date, filename, err)
+			continue
+		}
+		r.logger.Printf("Segment finalized: %s/%s (%v)", date, filename, seg.Duration().Round(time.Second))
+
+		// FFmpeg has already opened the next segment
+		go r.trackActiveSegment(ctx)
+	}
+}
+
+// segmentDate finds the date folder of a segment reported by FFmpeg. The
+// segment list only carries the file name, and a segment that started before
+// midnight lives in yesterday's folder.
+func (r *Recorder) segmentDate(filename string) (string, bool) {
+	now := time.Now()
+	for _, day := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		date := day.Format("2006-01-02")
+		if _, err := os.Stat(r.catalog.SegmentPath(r.camera.Name, date, filename)); err == nil {
+			return date, true
+		}
+	}
+	return "", false
+}
+
+// trackActiveSegment waits for the segment FFmpeg is writing to appear and
+// marks it active in the catalog, so it can be played back and checked for
+// staleness before it is finalized
+func (r *Recorder) trackActiveSegment(ctx context.Context) {
+	for attempt := 0; attempt < 15; attempt++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
+
+		date := time.Now().Format("2006-01-02")
+		entries, err := os.ReadDir(filepath.Join(r.storage.BasePath, r.camera.Name, "recordings", date))
+		if err != nil {
+			continue
+		}
+
+		// Segment names are HH-MM-SS, so the newest sorts last
+		newest := ""
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() && filepath.Ext(name) == r.camera.SegmentExtension() && name > newest {
+				newest = name
+			}
+		}
+
+		if newest != "" && !r.catalog.Contains(r.camera.Name, date, newest) {
+			r.catalog.SetActive(r.camera.Name, date, newest)
+			r.setState(StateRecording, PipelineRecord)
+			return
+		}
+	}
+}
+
+// finalizeActiveSegment repairs and catalogs the segment that was being
+// written when FFmpeg exited without reporting it
+func (r *Recorder) finalizeActiveSegment() {
+	seg, ok := r.catalog.ClearActive(r.camera.Name)
+	if !ok {
+		return
+	}
+
+	repaired, outcome, err := r.catalog.RepairFile(r.camera.Name, seg.Date, seg.Filename)
+	switch {
+	case outcome == catalog.RepairRemoved:
+		// Empty segment, nothing to keep
+	case err != nil:
+		r.logger.Printf("Failed to finalize interrupted segment %s/%s: %v", seg.Date, seg.Filename, err)
+	default:
+		r.logger.Printf("Interrupted segment finalized: %s/%s (%v, %s)",
+			seg.Date, seg.Filename, repaired.Duration().Round(time.Second), outcome)
+	}
 }
 
 // liveStream handles the live HLS streaming
 func (r *Recorder) liveStream(ctx context.Context) error {
-	// Create output directory for live stream
-	liveDir := filepath.Join(r.storage.BasePath, r.camera.Name, "live")
-	if err := os.MkdirAll(liveDir, 0755); err != nil {
-		return fmt.Errorf("creating live directory: %w", err)
+	segmenter := r.segmenters[PipelineLive]
+	segmenter.Discontinue()
+
+	r.liveStreamCmd = r.liveCommand(ctx, r.camera.LiveStream())
+
+	// FFmpeg writes MPEG-TS to stdout, which is segmented in memory
+	r.liveStreamCmd.Stdout = segmenter
+
+	// Log stderr for debugging
+	r.liveStreamCmd.Stderr = r.stderrWriter("LIVE", PipelineLive)
+
+	progress, err := r.monitorProgress(ctx, r.liveStreamCmd, PipelineLive)
+	if err != nil {
+		return err
+	}
+
+	// Start FFmpeg
+	if err := r.liveStreamCmd.Start(); err != nil {
+		progress.close()
+		return fmt.Errorf("starting live stream ffmpeg: %w", err)
 	}
 
-	// HLS playlist and segments
-	playlistPath := filepath.Join(liveDir, "stream.m3u8")
-	segmentPattern := filepath.Join(liveDir, "segment%03d.ts")
+	r.logger.Println("🔴 Live stream started (2-sec segments)")
+	r.setState(StateConnecting, PipelineLive)
+	progress.start(ctx)
+
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	go r.awaitSegment(runCtx, segmenter, PipelineLive)
 
-	// FFmpeg arguments for LIVE STREAMING (2-second segments)
+	// Wait for completion or context cancellation
+	return progress.result(r.liveStreamCmd.Wait())
+}
+
+// previewStream handles the low-resolution HLS stream used by grid tiles
+func (r *Recorder) previewStream(ctx context.Context) error {
+	segmenter := r.segmenters[PipelinePreview]
+	segmenter.Discontinue()
+
+	r.previewCmd = r.liveCommand(ctx, r.camera.PreviewURL)
+	r.previewCmd.Stdout = segmenter
+	r.previewCmd.Stderr = r.stderrWriter("PREVIEW", PipelinePreview)
+
+	progress, err := r.monitorProgress(ctx, r.previewCmd, PipelinePreview)
+	if err != nil {
+		return err
+	}
+
+	if err := r.previewCmd.Start(); err != nil {
+		progress.close()
+		return fmt.Errorf("starting preview stream ffmpeg: %w", err)
+	}
+
+	r.logger.Println("🟢 Preview stream started (2-sec segments)")
+	r.setState(StateConnecting, PipelinePreview)
+	progress.start(ctx)
+
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	go r.awaitSegment(runCtx, segmenter, PipelinePreview)
+
+	return progress.result(r.previewCmd.Wait())
+}
+
+// liveCommand builds an FFmpeg command that copies a camera stream to
+// MPEG-TS on stdout for the in-memory HLS segmenter
+func (r *Recorder) liveCommand(ctx context.Context, url string) *exec.Cmd {
 	args := []string{
 		"-hide_banner",
 		"-loglevel", "error",
@@ -200,39 +549,27 @@ func (r *Recorder) liveStream(ctx context.Context) error {
 		"-fflags", "nobuffer",
 		"-flags", "low_delay",
 		"-rtsp_transport", "tcp",
-		"-i", r.camera.URL,
+		"-i", url,
 
 		// Copy streams (no transcoding for efficiency)
 		"-c:v", "copy",
 		"-c:a", "copy",
-
-		// HLS output with LOW LATENCY settings
-		"-f", "hls",
-		"-hls_time", "2",            // 2-second segments for low latency
-		"-hls_list_size", "5",       // Keep only 5 segments (10 seconds)
-		"-hls_flags", "delete_segments+append_list",
-		"-hls_segment_type", "mpegts",
-		"-hls_segment_filename", segmentPattern,
-		"-hls_allow_cache", "0",
-
-		playlistPath,
 	}
+	args = append(args, progressArgs...)
 
-	// Create command with context
-	r.liveStreamCmd = exec.CommandContext(ctx, "ffmpeg", args...)
+	args = append(args, "-f", "mpegts")
+	args = append(args, muxerArgs(liveMuxerOptions())...)
+	args = append(args, "pipe:1")
 
-	// Log stderr for debugging
-	r.liveStreamCmd.Stderr = &logWriter{logger: r.logger, prefix: "LIVE"}
+	// Create command with context
+	return exec.CommandContext(ctx, "ffmpeg", args...)
+}
 
-	// Start FFmpeg
-	if err := r.liveStreamCmd.Start(); err != nil {
-		return fmt.Errorf("starting live stream ffmpeg: %w", err)
+// liveMuxerOptions returns the MPEG-TS muxer options for live output
+func liveMuxerOptions() []muxerOption {
+	return []muxerOption{
+		{"flush_packets", "1"}, // Hand each packet to the segmenter right away
 	}
-
-	r.logger.Println("🔴 Live stream started (2-sec segments)")
-
-	// Wait for completion or context cancellation
-	return r.liveStreamCmd.Wait()
 }
 
 // Stop gracefully stops both recording and live streaming
@@ -242,44 +579,34 @@ func (r *Recorder) Stop() {
 		r.cancel()
 	}
 
-	// Stop recording
-	if r.recordCmd != nil && r.recordCmd.Process != nil {
-		r.logger.Println("Stopping recording...")
-		r.recordCmd.Process.Signal(os.Interrupt)
+	r.stopCmd(r.recordCmd, "recording", "Recording")
+	r.stopCmd(r.liveStreamCmd, "live stream", "Live stream")
+	r.stopCmd(r.previewCmd, "preview stream", "Preview stream")
 
-		done := make(chan struct{})
-		go func() {
-			r.recordCmd.Wait()
-			close(done)
-		}()
+	r.setState(StateStopped, PipelineRecord, PipelineLive, PipelinePreview)
+}
 
-		select {
-		case <-done:
-			r.logger.Println("Recording stopped gracefully")
-		case <-time.After(5 * time.Second):
-			r.logger.Println("Force killing recording")
-			r.recordCmd.Process.Kill()
-		}
+// stopCmd interrupts an FFmpeg process, killing it if it doesn't exit in time
+func (r *Recorder) stopCmd(cmd *exec.Cmd, name, title string) {
+	if cmd == nil || cmd.Process == nil {
+		return
 	}
 
-	// Stop live stream
-	if r.liveStreamCmd != nil && r.liveStreamCmd.Process != nil {
-		r.logger.Println("Stopping live stream...")
-		r.liveStreamCmd.Process.Signal(os.Interrupt)
-
-		done := make(chan struct{})
-		go func() {
-			r.liveStreamCmd.Wait()
-			close(done)
-		}()
-
-		select {
-		case <-done:
-			r.logger.Println("Live stream stopped gracefully")
-		case <-time.After(5 * time.Second):
-			r.logger.Println("Force killing live stream")
-			r.liveStreamCmd.Process.Kill()
-		}
+	r.logger.Printf("Stopping %s...", name)
+	cmd.Process.Signal(os.Interrupt)
+
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		r.logger.Printf("%s stopped gracefully", title)
+	case <-time.After(5 * time.Second):
+		r.logger.Printf("Force killing %s", name)
+		cmd.Process.Kill()
 	}
 }
 
@@ -300,35 +627,21 @@ func (r *Recorder) Restart(parentCtx context.Context) error {
 	return nil
 }
 
-// GetLastRecordingTime returns the time of the last recording segment
+// GetLastRecordingTime returns when the recording last advanced: FFmpeg's
+// latest progress while it runs, otherwise the end of the last segment
 func (r *Recorder) GetLastRecordingTime() time.Time {
-	// Check the most recent file in today's recording directory
-	dateStr := time.Now().Format("2006-01-02")
-	recordDir := filepath.Join(r.storage.BasePath, r.camera.Name, "recordings", dateStr)
-
-	// Find the most recent .ts file
-	entries, err := os.ReadDir(recordDir)
-	if err != nil {
-		// If today's folder doesn't exist, try yesterday (for overnight transitions)
-		yesterday := time.Now().Add(-24 * time.Hour).Format("2006-01-02")
-		recordDir = filepath.Join(r.storage.BasePath, r.camera.Name, "recordings", yesterday)
-		entries, err = os.ReadDir(recordDir)
-		if err != nil {
-			return time.Time{} // Return zero time if neither directory exists
-		}
+	var last time.Time
+	if progress := r.Status().Record.Progress; progress != nil {
+		last = progress.LastFrameAt
 	}
 
-	var latestTime time.Time
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".ts" {
-			info, err := entry.Info()
-			if err == nil && info.ModTime().After(latestTime) {
-				latestTime = info.ModTime()
-			}
-		}
+	// The catalog refreshes the segment being written from its file, so this
+	// reflects the latest write rather than the last finished segment
+	if seg, ok := r.catalog.Latest(r.camera.Name); ok && seg.End.After(last) {
+		last = seg.End
 	}
 
-	return latestTime
+	return last
 }
 
 // GetCameraName returns the camera name
@@ -383,8 +696,9 @@ func (r *Recorder) getOutputDir() string {
 
 // logWriter wraps a logger for stderr output
 type logWriter struct {
-	logger *log.Logger
-	prefix string
+	logger  *log.Logger
+	prefix  string
+	onError func(reason FailureReason, line string) // Called for recognized FFmpeg errors
 }
 
 func (lw *logWriter) Write(p []byte) (n int, err error) {
@@ -393,5 +707,25 @@ func (lw *logWriter) Write(p []byte) (n int, err error) {
 	} else {
 		lw.logger.Printf("FFmpeg: %s", p)
 	}
+
+	if lw.onError != nil {
+		for _, line := range strings.Split(string(p), "\n") {
+			if reason := ClassifyFFmpegError(line); reason != FailureNone {
+				lw.onError(reason, strings.TrimSpace(line))
+			}
+		}
+	}
 	return len(p), nil
-}
\ No newline at end of file
+}
+
+// stderrWriter returns a logWriter that also records classified FFmpeg
+// errors on the given pipelines' status
+func (r *Recorder) stderrWriter(prefix string, pipelines ...string) *logWriter {
+	return &logWriter{
+		logger: r.logger,
+		prefix: prefix,
+		onError: func(reason FailureReason, line string) {
+			r.setFFmpegError(reason, line, pipelines...)
+		},
+	}
+}
@@ -0,0 +1,194 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+)
+
+const (
+	// snapshotQuality is FFmpeg's -q:v for snapshots, from 2 (best) to 31
+	snapshotQuality = 3
+
+	// snapshotTimeout bounds a one-shot FFmpeg connecting to the camera
+	snapshotTimeout = 20 * time.Second
+
+	// liveKeyframeMaxAge is how old the live stream's latest keyframe may be
+	// to count as the camera's current view
+	liveKeyframeMaxAge = 10 * time.Second
+)
+
+// snapshotDir is the folder under a camera's storage folder that holds
+// periodic snapshots, in date folders like recordings
+const snapshotDir = "snapshots"
+
+// Snapshot returns a JPEG of the camera's current view. It decodes the live
+// stream's latest keyframe if the live stream is running, or grabs a frame
+// from the camera with a one-shot FFmpeg otherwise.
+func (r *Recorder) Snapshot(ctx context.Context) ([]byte, error) {
+	// Requests that arrive while a snapshot is being taken share it
+	started := time.Now()
+	r.snapshotGrab.Lock()
+	defer r.snapshotGrab.Unlock()
+	if img, at, ok := r.LatestSnapshot(); ok && !at.Before(started) {
+		return img, nil
+	}
+
+	var img []byte
+	var err error
+	if frame, codec, ok := r.liveKeyframe(); ok {
+		img, err = r.decodeKeyframe(ctx, frame, codec)
+	} else {
+		img, err = r.grabFrame(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.statusMu.Lock()
+	r.snapshot = img
+	r.snapshotAt = time.Now()
+	r.statusMu.Unlock()
+	return img, nil
+}
+
+// LatestSnapshot returns the last snapshot taken and when it was taken
+func (r *Recorder) LatestSnapshot() ([]byte, time.Time, bool) {
+	r.statusMu.RLock()
+	defer r.statusMu.RUnlock()
+
+	if r.snapshot == nil {
+		return nil, time.Time{}, false
+	}
+	return r.snapshot, r.snapshotAt, true
+}
+
+// liveKeyframe returns a recent keyframe of the live stream and its codec
+func (r *Recorder) liveKeyframe() (hls.Frame, string, bool) {
+	segmenter, ok := r.segmenters[PipelineLive]
+	if !ok {
+		return hls.Frame{}, "", false
+	}
+	frame, ok := segmenter.Keyframe(liveKeyframeMaxAge)
+	if !ok {
+		return hls.Frame{}, "", false
+	}
+	return frame, segmenter.VideoCodec(), true
+}
+
+// decodeKeyframe turns a keyframe of the live stream into a JPEG
+func (r *Recorder) decodeKeyframe(ctx context.Context, frame hls.Frame, codec string) ([]byte, error) {
+	input, ok := hls.InputFormat(codec)
+	if !ok {
+		return nil, fmt.Errorf("unsupported video codec %q", codec)
+	}
+
+	return r.runSnapshotCmd(ctx, bytes.NewReader(frame.Data), "-f", input, "-i", "pipe:0")
+}
+
+// grabFrame connects to the camera's live stream URL for a single frame
+func (r *Recorder) grabFrame(ctx context.Context) ([]byte, error) {
+	return r.runSnapshotCmd(ctx, nil, "-rtsp_transport", "tcp", "-i", r.camera.LiveStream())
+}
+
+// runSnapshotCmd runs FFmpeg with the given input arguments and returns the
+// first video frame as a JPEG
+func (r *Recorder) runSnapshotCmd(ctx context.Context, stdin *bytes.Reader, inputArgs ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	args = append(args, inputArgs...)
+	args = append(args,
+		"-an",
+		"-frames:v", "1",
+		"-vf", "format=yuvj420p",
+		"-c:v", "mjpeg",
+		"-q:v", strconv.Itoa(snapshotQuality),
+		"-f", "image2pipe",
+		"pipe:1",
+	)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, errors.New("timed out taking snapshot")
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("taking snapshot: %s", msg)
+		}
+		return nil, fmt.Errorf("taking snapshot: %w", err)
+	}
+	if stdout.Len() == 0 {
+		return nil, errors.New("taking snapshot: no video frame")
+	}
+	return stdout.Bytes(), nil
+}
+
+// runSnapshots stores a snapshot every snapshot_interval seconds while
+// recording is on, under <base>/<camera>/snapshots/<date>/
+func (r *Recorder) runSnapshots(ctx context.Context) {
+	interval := time.Duration(r.camera.SnapshotInterval) * time.Second
+	r.logger.Printf("📸 Taking snapshots every %v", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Paused or scheduled-off cameras keep their privacy
+		if expected, _ := r.RecordingExpected(); !expected {
+			continue
+		}
+
+		img, err := r.Snapshot(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Printf("Snapshot failed: %v", err)
+			}
+			continue
+		}
+		if err := r.saveSnapshot(img, time.Now()); err != nil {
+			r.logger.Printf("Failed to save snapshot: %v", err)
+		}
+	}
+}
+
+// saveSnapshot writes a snapshot to its date folder
+func (r *Recorder) saveSnapshot(img []byte, at time.Time) error {
+	dir := filepath.Join(r.storage.BasePath, r.camera.Name, snapshotDir, at.Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating snapshot directory: %w", err)
+	}
+
+	path := filepath.Join(dir, at.Format("15-04-05")+".jpg")
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, img, 0644); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("replacing snapshot: %w", err)
+	}
+	return nil
+}
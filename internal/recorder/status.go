@@ -0,0 +1,215 @@
+package recorder
+
+import (
+	"context"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+	"github.com/mmuteeullah/CoreNVR/internal/tamper"
+)
+
+// State is the lifecycle state of an FFmpeg pipeline
+type State string
+
+const (
+	StateStarting   State = "starting"           // Recorder started, FFmpeg not launched yet
+	StateConnecting State = "connecting"         // FFmpeg running, no output written yet
+	StateRecording  State = "recording"          // FFmpeg is writing output
+	StateBackoff    State = "backoff"            // FFmpeg exited, waiting to retry
+	StateStopped    State = "stopped"            // Recorder stopped
+	StateFailed     State = "failed-max-retries" // Gave up after max_retries
+
+	StateScheduledOff State = "scheduled-off" // Outside the camera's recording schedule
+	StatePaused       State = "paused"        // Recording paused manually
+	StateIdle         State = "idle"          // On-demand live stream waiting for a viewer
+)
+
+// Pipeline names used in Status
+const (
+	PipelineRecord  = "record"
+	PipelineLive    = "live"
+	PipelinePreview = "preview"
+)
+
+// PipelineStatus describes the state of one FFmpeg pipeline
+type PipelineStatus struct {
+	State       State     `json:"state"`
+	Since       time.Time `json:"since"`
+	RetryCount  int       `json:"retry_count"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+	StartedAt   time.Time `json:"started_at,omitempty"` // When the current FFmpeg process started
+
+	// Classified cause of the most recent FFmpeg error, cleared once output resumes
+	FailureReason FailureReason `json:"failure_reason,omitempty"`
+	FailureDetail string        `json:"failure_detail,omitempty"` // FFmpeg's error line
+
+	// Latest FFmpeg -progress report while the process is running
+	Progress *Progress `json:"progress,omitempty"`
+
+	// Clients watching a live or preview stream
+	Viewers int `json:"viewers,omitempty"`
+}
+
+// Status describes the state of a camera's recorder
+type Status struct {
+	Camera       string          `json:"camera"`
+	SingleIngest bool            `json:"single_ingest"`
+	Record       PipelineStatus  `json:"record"`
+	Live         PipelineStatus  `json:"live"`
+	Preview      *PipelineStatus `json:"preview,omitempty"`
+	Paused       *PauseStatus    `json:"paused,omitempty"`
+	Tamper       *tamper.State   `json:"tamper,omitempty"` // nil unless tamper detection is on
+}
+
+// Status returns a snapshot of the recorder's pipeline states
+func (r *Recorder) Status() Status {
+	r.statusMu.RLock()
+	defer r.statusMu.RUnlock()
+
+	status := Status{
+		Camera:       r.camera.Name,
+		SingleIngest: r.camera.SingleIngest,
+		Record:       *r.pipelines[PipelineRecord],
+		Live:         *r.pipelines[PipelineLive],
+	}
+
+	if r.camera.PreviewURL != "" {
+		preview := *r.pipelines[PipelinePreview]
+		status.Preview = &preview
+	}
+
+	status.Live.Viewers = r.Viewers(PipelineLive)
+	if status.Preview != nil {
+		status.Preview.Viewers = r.Viewers(PipelinePreview)
+	}
+
+	if r.pause.Paused {
+		status.Paused = &PauseStatus{Since: r.pause.Since, ResumeAt: r.pause.ResumeAt}
+	}
+
+	if r.camera.Tamper != nil && r.camera.Tamper.Enabled {
+		state := r.tamper
+		status.Tamper = &state
+	}
+
+	return status
+}
+
+// IsRecording reports whether the record pipeline is currently writing segments
+func (r *Recorder) IsRecording() bool {
+	return r.Status().Record.State == StateRecording
+}
+
+// newPipelineStatuses creates the initial status of every pipeline
+func newPipelineStatuses() map[string]*PipelineStatus {
+	now := time.Now()
+	return map[string]*PipelineStatus{
+		PipelineRecord:  {State: StateStopped, Since: now},
+		PipelineLive:    {State: StateStopped, Since: now},
+		PipelinePreview: {State: StateStopped, Since: now},
+	}
+}
+
+// setState moves pipelines to a new state
+func (r *Recorder) setState(state State, pipelines ...string) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	now := time.Now()
+	for _, name := range pipelines {
+		p := r.pipelines[name]
+		if p.State == state {
+			continue
+		}
+
+		p.State = state
+		p.Since = now
+
+		switch state {
+		case StateStarting:
+			p.RetryCount = 0
+		case StateConnecting:
+			p.StartedAt = now
+		case StateRecording:
+			// Output confirms the connection works
+			p.RetryCount = 0
+			p.FailureReason = FailureNone
+			p.FailureDetail = ""
+		case StateBackoff, StateStopped, StateFailed, StateScheduledOff, StatePaused, StateIdle:
+			p.Progress = nil
+		}
+	}
+}
+
+// setFailure records a pipeline failure and the retry count so far
+func (r *Recorder) setFailure(err error, retryCount int, pipelines ...string) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	now := time.Now()
+	for _, name := range pipelines {
+		p := r.pipelines[name]
+		p.RetryCount = retryCount
+		if err != nil {
+			p.LastError = err.Error()
+			p.LastErrorAt = now
+		}
+	}
+}
+
+// setProgress publishes the latest FFmpeg progress report on pipelines.
+// Reports are never modified after publishing, so pipelines share them.
+func (r *Recorder) setProgress(progress *Progress, pipelines ...string) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	for _, name := range pipelines {
+		r.pipelines[name].Progress = progress
+	}
+}
+
+// setFFmpegError records a classified FFmpeg error on pipelines
+func (r *Recorder) setFFmpegError(reason FailureReason, line string, pipelines ...string) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	now := time.Now()
+	for _, name := range pipelines {
+		p := r.pipelines[name]
+		if p.FailureReason != reason {
+			r.logger.Printf("⚠️  %s pipeline: %s", name, reason.Description())
+		}
+		p.FailureReason = reason
+		p.FailureDetail = line
+		p.LastError = line
+		p.LastErrorAt = now
+	}
+}
+
+// FailureReason returns the classified cause of the record pipeline's most
+// recent failure, if it hasn't recovered since
+func (r *Recorder) FailureReason() FailureReason {
+	return r.Status().Record.FailureReason
+}
+
+// awaitSegment marks live pipelines as recording once the segmenter
+// finishes a segment after the process started
+func (r *Recorder) awaitSegment(ctx context.Context, segmenter *hls.Segmenter, pipelines ...string) {
+	started := time.Now()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if segmenter.LastSegmentAt().After(started) {
+				r.setState(StateRecording, pipelines...)
+				return
+			}
+		}
+	}
+}
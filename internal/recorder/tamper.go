@@ -0,0 +1,89 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/events"
+	"github.com/mmuteeullah/CoreNVR/internal/tamper"
+)
+
+// Tamper returns the camera's tamper state, and false if tamper detection
+// is off
+func (r *Recorder) Tamper() (tamper.State, bool) {
+	if r.camera.Tamper == nil || !r.camera.Tamper.Enabled {
+		return tamper.State{}, false
+	}
+
+	r.statusMu.RLock()
+	defer r.statusMu.RUnlock()
+	return r.tamper, true
+}
+
+// watchVideoLoss reports video loss when analysis gets no frames, until ctx
+// is cancelled
+func (r *Recorder) watchVideoLoss(ctx context.Context, detector *tamper.Detector) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if state, ok := detector.Check(now); ok {
+				r.setTamper(state)
+			}
+		}
+	}
+}
+
+// setTamper records a new tamper state and stores it as an event
+func (r *Recorder) setTamper(state tamper.State) {
+	r.tamperMu.Lock()
+	defer r.tamperMu.Unlock()
+
+	r.statusMu.Lock()
+	previous := r.tamper
+	r.tamper = state
+	event := r.tamperEvent
+	r.tamperEvent = nil
+	r.statusMu.Unlock()
+
+	now := time.Now()
+	if previous.Tampered {
+		r.logger.Printf("✅ Tamper cleared (%s for %v)", previous.Reason, now.Sub(previous.Since).Round(time.Second))
+		if event != nil && eventStore != nil {
+			event.End = &now
+			if err := eventStore.Update(*event); err != nil {
+				r.logger.Printf("Failed to store tamper event: %v", err)
+			}
+		}
+	}
+	if !state.Tampered {
+		return
+	}
+
+	r.logger.Printf("🚨 Tamper detected: %s since %s", state.Reason, state.Since.Format("15:04:05"))
+	if notify != nil {
+		notify(fmt.Sprintf("Camera %s tampered: %s since %s", r.camera.Name, state.Reason, state.Since.Format("15:04:05")))
+	}
+	if eventStore == nil {
+		return
+	}
+	stored, err := eventStore.Add(events.Event{
+		Camera: r.camera.Name,
+		Type:   events.TypeTamper,
+		Start:  state.Since,
+		Reason: state.Reason,
+	})
+	if err != nil {
+		r.logger.Printf("Failed to store tamper event: %v", err)
+		return
+	}
+
+	r.statusMu.Lock()
+	r.tamperEvent = &stored
+	r.statusMu.Unlock()
+}
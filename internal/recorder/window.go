@@ -0,0 +1,128 @@
+package recorder
+
+import (
+	"context"
+	"time"
+)
+
+// runRecordWindow runs on while recording is wanted, and off while the
+// camera's schedule or a manual pause has recording off. off may be nil when
+// nothing needs to run meanwhile.
+func (r *Recorder) runRecordWindow(ctx context.Context, on, off func(context.Context)) {
+	schedule := r.camera.Schedule
+
+	var running bool
+	var cancel context.CancelFunc
+	var done chan struct{}
+
+	// stop ends the current run and waits for its pipelines to stop
+	stop := func() {
+		if cancel != nil {
+			cancel()
+			<-done
+		}
+	}
+
+	for first := true; ; first = false {
+		now := time.Now()
+
+		pause, paused := r.PauseStatus()
+		if paused && !pause.ResumeAt.IsZero() && !now.Before(pause.ResumeAt) {
+			if err := r.Resume(); err != nil {
+				r.logger.Printf("WARNING: Failed to resume recording: %v", err)
+			}
+			pause, paused = r.PauseStatus()
+		}
+
+		scheduled := schedule == nil || schedule.Active(now)
+		recording := scheduled && !paused
+		r.setRecordingExpected(recording, now)
+
+		if first || recording != running {
+			stop()
+
+			run := off
+			switch {
+			case recording:
+				if schedule != nil {
+					r.logger.Println("⏰ Recording scheduled, starting")
+				}
+				run = on
+			case paused:
+				r.logger.Println("⏸️  Recording paused, record pipeline stopped")
+			default:
+				r.logger.Println("⏰ Outside recording schedule, recording off")
+			}
+
+			runCtx, runCancel := context.WithCancel(ctx)
+			cancel, done = runCancel, make(chan struct{})
+			go func(done chan struct{}) {
+				defer close(done)
+				if run != nil {
+					run(runCtx)
+				}
+			}(done)
+			running = recording
+		}
+
+		// The previous run has stopped its pipelines, so this state sticks
+		switch {
+		case paused:
+			r.setState(StatePaused, PipelineRecord)
+		case !scheduled:
+			r.setState(StateScheduledOff, PipelineRecord)
+		}
+
+		// Wake up for the next schedule change or auto-resume
+		wait := 24 * time.Hour
+		if schedule != nil {
+			if next := schedule.NextChange(now); !next.IsZero() {
+				wait = next.Sub(now)
+			}
+		}
+		if paused && !pause.ResumeAt.IsZero() && pause.ResumeAt.Sub(now) < wait {
+			wait = pause.ResumeAt.Sub(now)
+		}
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			stop()
+			return
+		case <-timer.C:
+		case <-r.recordWake:
+			timer.Stop()
+		}
+	}
+}
+
+// wakeRecordWindow makes the record window re-evaluate whether to record
+func (r *Recorder) wakeRecordWindow() {
+	select {
+	case r.recordWake <- struct{}{}:
+	default:
+	}
+}
+
+// setRecordingExpected records whether the record pipeline should be running
+func (r *Recorder) setRecordingExpected(expected bool, now time.Time) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	if expected && r.recordOff {
+		r.recordOnAt = now
+	}
+	r.recordOff = !expected
+}
+
+// RecordingExpected reports whether the record pipeline should be running
+// now, rather than being scheduled off or paused. If recording was switched
+// back on since the recorder was created, it also returns when, so
+// staleness checks can allow for the restart.
+func (r *Recorder) RecordingExpected() (bool, time.Time) {
+	r.statusMu.RLock()
+	defer r.statusMu.RUnlock()
+
+	return !r.recordOff, r.recordOnAt
+}
@@ -23,6 +23,9 @@ type CameraRecoveryState struct {
 	lastRecoveryCheck time.Time
 	recoveryAttempts  []RecoveryAttempt
 	mutex             sync.Mutex
+
+	// Start of the tamper already alerted, zero if none
+	tamperAlerted time.Time
 }
 
 // RecoveryAttempt records a recovery action
@@ -77,6 +80,28 @@ func NewRecoveryManager(cfg *config.RecoveryConfig, recorders []*recorder.Record
 	return rm, nil
 }
 
+// AddRecorder starts monitoring a recorder added at runtime, replacing any
+// previous recorder of the same camera
+func (rm *RecoveryManager) AddRecorder(rec *recorder.Recorder) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	name := rec.GetCameraName()
+	rm.recorders[name] = rec
+	rm.states[name] = &CameraRecoveryState{
+		cameraName: name,
+	}
+}
+
+// RemoveRecorder stops monitoring a camera's recorder
+func (rm *RecoveryManager) RemoveRecorder(name string) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	delete(rm.recorders, name)
+	delete(rm.states, name)
+}
+
 // Start begins the recovery monitoring loop
 func (rm *RecoveryManager) Start(ctx context.Context) {
 	rm.logger.Println("Starting camera recovery monitor...")
@@ -97,24 +122,46 @@ func (rm *RecoveryManager) Start(ctx context.Context) {
 
 // checkAllCameras checks health of all cameras
 func (rm *RecoveryManager) checkAllCameras() {
+	// Recovery can take minutes, so check a snapshot rather than holding
+	// the lock while cameras are added or removed
 	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
-
+	recorders := make(map[string]*recorder.Recorder, len(rm.recorders))
+	states := make(map[string]*CameraRecoveryState, len(rm.states))
 	for cameraName, rec := range rm.recorders {
-		if err := rm.checkCamera(cameraName, rec); err != nil {
+		recorders[cameraName] = rec
+		states[cameraName] = rm.states[cameraName]
+	}
+	rm.mutex.RUnlock()
+
+	for cameraName, rec := range recorders {
+		if err := rm.checkCamera(cameraName, rec, states[cameraName]); err != nil {
 			rm.logger.Printf("Error checking camera %s: %v", cameraName, err)
 		}
 	}
 }
 
 // checkCamera checks a single camera's health
-func (rm *RecoveryManager) checkCamera(cameraName string, rec *recorder.Recorder) error {
-	state := rm.states[cameraName]
+func (rm *RecoveryManager) checkCamera(cameraName string, rec *recorder.Recorder, state *CameraRecoveryState) error {
 	state.mutex.Lock()
 	defer state.mutex.Unlock()
 
-	// Get last recording time
+	// A tampered camera keeps recording, so staleness won't show it
+	rm.checkTamper(cameraName, rec, state)
+
+	// Outside its schedule a camera isn't supposed to record
+	expected, scheduledOnAt := rec.RecordingExpected()
+	if !expected {
+		state.failureDetectedAt = time.Time{}
+		state.recoveryAttempts = nil
+		return nil
+	}
+
+	// Get last recording time, counting from when the schedule turned
+	// recording back on
 	lastRecording := rec.GetLastRecordingTime()
+	if scheduledOnAt.After(lastRecording) {
+		lastRecording = scheduledOnAt
+	}
 
 	// Check if recording is stale
 	if lastRecording.IsZero() {
@@ -141,7 +188,7 @@ func (rm *RecoveryManager) checkCamera(cameraName string, rec *recorder.Recorder
 	if state.failureDetectedAt.IsZero() {
 		// First detection of stale recording
 		state.failureDetectedAt = time.Now()
-		rm.logger.Printf("⚠️  Camera %s: Stale recording detected (%v old)", cameraName, age.Round(time.Second))
+		rm.logger.Printf("⚠️  Camera %s: Stale recording detected (%v old)%s", cameraName, age.Round(time.Second), failureSuffix(rec))
 		return nil
 	}
 
@@ -158,10 +205,33 @@ func (rm *RecoveryManager) checkCamera(cameraName string, rec *recorder.Recorder
 	}
 
 	// Failure verified - attempt recovery
-	rm.logger.Printf("🚨 Camera %s: Recording stale for %v, starting recovery...", cameraName, age.Round(time.Second))
+	rm.logger.Printf("🚨 Camera %s: Recording stale for %v, starting recovery...%s", cameraName, age.Round(time.Second), failureSuffix(rec))
 	return rm.recoverCamera(cameraName, rec, state)
 }
 
+// checkTamper alerts once when a camera's image turns black, covered or
+// frozen, and again when it's back. Restarting wouldn't help, so no
+// recovery is attempted.
+func (rm *RecoveryManager) checkTamper(cameraName string, rec *recorder.Recorder, state *CameraRecoveryState) {
+	tamper, ok := rec.Tamper()
+	if !ok || !tamper.Tampered {
+		if !state.tamperAlerted.IsZero() {
+			rm.logger.Printf("✅ Camera %s: Tamper cleared", cameraName)
+			rm.sendAlert(fmt.Sprintf("✅ *Camera Tamper Cleared*\nCamera: `%s`\nImage is back to normal", cameraName))
+			state.tamperAlerted = time.Time{}
+		}
+		return
+	}
+	if state.tamperAlerted.Equal(tamper.Since) {
+		return
+	}
+
+	rm.logger.Printf("🚫 Camera %s: Tamper detected (%s since %s)", cameraName, tamper.Reason, tamper.Since.Format("15:04:05"))
+	rm.sendAlert(fmt.Sprintf("🚫 *Camera Tampered*\nCamera: `%s`\nCondition: %s\nSince: %s",
+		cameraName, tamper.Reason, tamper.Since.Format("2006-01-02 15:04:05")))
+	state.tamperAlerted = tamper.Since
+}
+
 // recoverCamera attempts to recover a camera
 func (rm *RecoveryManager) recoverCamera(cameraName string, rec *recorder.Recorder, state *CameraRecoveryState) error {
 	// Check if we've exceeded power cycle limits
@@ -188,15 +258,15 @@ func (rm *RecoveryManager) recoverCamera(cameraName string, rec *recorder.Record
 	}
 
 	// All attempts exhausted
-	rm.sendAlert(fmt.Sprintf("💀 *CRITICAL: All Recovery Attempts Failed*\nCamera: `%s`\nAll recovery methods exhausted\nImmediate attention required",
-		cameraName))
+	rm.sendAlert(fmt.Sprintf("💀 *CRITICAL: All Recovery Attempts Failed*\nCamera: `%s`\nAll recovery methods exhausted\nImmediate attention required%s",
+		cameraName, failureLine(rec)))
 	return fmt.Errorf("all recovery attempts failed")
 }
 
 // restartCameraGoroutine restarts just the camera's recorder goroutine
 func (rm *RecoveryManager) restartCameraGoroutine(cameraName string, rec *recorder.Recorder, state *CameraRecoveryState) error {
 	rm.logger.Printf("🔄 Level 1: Restarting recorder goroutine for %s", cameraName)
-	rm.sendAlert(fmt.Sprintf("🔄 *Recovery Started*\nCamera: `%s`\nAction: Restarting recorder goroutine", cameraName))
+	rm.sendAlert(fmt.Sprintf("🔄 *Recovery Started*\nCamera: `%s`\nAction: Restarting recorder goroutine%s", cameraName, failureLine(rec)))
 
 	state.recoveryAttempts = append(state.recoveryAttempts, RecoveryAttempt{
 		Timestamp: time.Now(),
@@ -282,6 +352,24 @@ func (rm *RecoveryManager) countRecentAttempts(state *CameraRecoveryState, actio
 	return count
 }
 
+// failureSuffix formats a recorder's classified failure for log lines
+func failureSuffix(rec *recorder.Recorder) string {
+	reason := rec.FailureReason()
+	if reason == recorder.FailureNone {
+		return ""
+	}
+	return fmt.Sprintf(" - %s", reason.Description())
+}
+
+// failureLine formats a recorder's classified failure for Slack alerts
+func failureLine(rec *recorder.Recorder) string {
+	reason := rec.FailureReason()
+	if reason == recorder.FailureNone {
+		return ""
+	}
+	return fmt.Sprintf("\nReason: %s (`%s`)", reason.Description(), reason)
+}
+
 // SlackMessage represents a Slack webhook payload
 type SlackMessage struct {
 	Text string `json:"text"`
@@ -0,0 +1,207 @@
+// Package rtc streams live camera video to browsers over WebRTC
+package rtc
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+)
+
+const (
+	// frameBuffer is how many frames a session may fall behind before it
+	// skips to the next keyframe
+	frameBuffer = 30
+
+	// keepAliveInterval is how often a session tells the recorder it's still
+	// watching, so on-demand live streams keep running
+	keepAliveInterval = 5 * time.Second
+
+	// defaultFrameDuration is used until two frames give the real frame rate
+	defaultFrameDuration = time.Second / 15
+
+	// connectTimeout drops sessions whose browser never connected
+	connectTimeout = 30 * time.Second
+)
+
+// Server negotiates WebRTC sessions that send a camera's live H.264 video
+// as is. It only offers host candidates, so no STUN or TURN server is needed
+// on the local network.
+type Server struct {
+	api    *webrtc.API
+	logger *log.Logger
+}
+
+// session is one browser watching a camera
+type session struct {
+	camera    string
+	pc        *webrtc.PeerConnection
+	track     *webrtc.TrackLocalStaticSample
+	mu        sync.Mutex
+	connected bool
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewServer sets up WebRTC with the given settings
+func NewServer(cfg config.WebRTCConfig) (*Server, error) {
+	mediaEngine := &webrtc.MediaEngine{}
+	if err := mediaEngine.RegisterDefaultCodecs(); err != nil {
+		return nil, fmt.Errorf("registering codecs: %w", err)
+	}
+
+	// NACKs and receiver reports keep video smooth on lossy Wi-Fi
+	interceptors := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(mediaEngine, interceptors); err != nil {
+		return nil, fmt.Errorf("registering interceptors: %w", err)
+	}
+
+	settings := webrtc.SettingEngine{}
+	settings.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeUDP4, webrtc.NetworkTypeUDP6})
+	if cfg.UDPPortMin != 0 {
+		if err := settings.SetEphemeralUDPPortRange(uint16(cfg.UDPPortMin), uint16(cfg.UDPPortMax)); err != nil {
+			return nil, fmt.Errorf("setting UDP port range: %w", err)
+		}
+	}
+
+	return &Server{
+		api: webrtc.NewAPI(
+			webrtc.WithMediaEngine(mediaEngine),
+			webrtc.WithInterceptorRegistry(interceptors),
+			webrtc.WithSettingEngine(settings),
+		),
+		logger: log.New(os.Stdout, "[WebRTC] ", log.LstdFlags),
+	}, nil
+}
+
+// Answer accepts a browser's SDP offer and returns the answer, with all ICE
+// candidates included. The session then streams the source's video until the
+// browser disconnects, calling keepAlive every few seconds meanwhile.
+func (s *Server) Answer(camera, offer string, source *hls.Segmenter, keepAlive func()) (string, error) {
+	pc, err := s.api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return "", fmt.Errorf("creating peer connection: %w", err)
+	}
+
+	track, err := webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000},
+		"video", camera)
+	if err != nil {
+		pc.Close()
+		return "", fmt.Errorf("creating track: %w", err)
+	}
+
+	sender, err := pc.AddTrack(track)
+	if err != nil {
+		pc.Close()
+		return "", fmt.Errorf("adding track: %w", err)
+	}
+
+	// RTCP has to be read for the interceptors to see it
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := sender.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offer}); err != nil {
+		pc.Close()
+		return "", fmt.Errorf("reading offer: %w", err)
+	}
+
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		pc.Close()
+		return "", fmt.Errorf("creating answer: %w", err)
+	}
+
+	// Host candidates gather almost instantly, so skip trickle ICE
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		pc.Close()
+		return "", fmt.Errorf("setting answer: %w", err)
+	}
+	<-gathered
+
+	sess := &session{camera: camera, pc: pc, track: track, done: make(chan struct{})}
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			sess.mu.Lock()
+			sess.connected = true
+			sess.mu.Unlock()
+			s.logger.Printf("📞 %s: viewer connected", camera)
+
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateClosed:
+			sess.closeOnce.Do(func() { close(sess.done) })
+		}
+	})
+
+	go s.stream(sess, source, keepAlive)
+
+	return pc.LocalDescription().SDP, nil
+}
+
+// stream sends the source's frames to a session until it ends
+func (s *Server) stream(sess *session, source *hls.Segmenter, keepAlive func()) {
+	frames, unsubscribe := source.Subscribe(frameBuffer)
+	defer unsubscribe()
+
+	keepAliveTicker := time.NewTicker(keepAliveInterval)
+	defer keepAliveTicker.Stop()
+	connectTimer := time.NewTimer(connectTimeout)
+	defer connectTimer.Stop()
+
+	var lastPTS int64
+	duration := defaultFrameDuration
+
+	for {
+		select {
+		case <-sess.done:
+			sess.pc.Close()
+			if sess.isConnected() {
+				s.logger.Printf("📴 %s: viewer disconnected", sess.camera)
+			}
+			return
+
+		case <-connectTimer.C:
+			if !sess.isConnected() {
+				sess.pc.Close()
+			}
+
+		case <-keepAliveTicker.C:
+			keepAlive()
+
+		case frame := <-frames:
+			// Each frame lasts about as long as the previous one did
+			if lastPTS != 0 {
+				if d := time.Duration(frame.PTS-lastPTS) * time.Second / 90000; d > 0 && d < time.Second {
+					duration = d
+				}
+			}
+			lastPTS = frame.PTS
+
+			if err := sess.track.WriteSample(media.Sample{Data: frame.Data, Duration: duration}); err != nil {
+				sess.pc.Close()
+			}
+		}
+	}
+}
+
+// isConnected reports whether the browser ever connected
+func (sess *session) isConnected() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.connected
+}
@@ -0,0 +1,336 @@
+package rtsp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/pion/rtp"
+
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+	"github.com/mmuteeullah/CoreNVR/internal/recorder"
+)
+
+const (
+	// frameBuffer is how many frames a re-stream may fall behind before it
+	// skips to the next keyframe
+	frameBuffer = 30
+
+	// keepAliveInterval is how often a re-stream tells the recorder its
+	// clients are still watching, and updates its bitrate
+	keepAliveInterval = 5 * time.Second
+
+	// idleTimeout stops re-streams nobody has played for this long
+	idleTimeout = 10 * time.Second
+
+	// startTimeout is how long to wait for the first keyframe, which may
+	// need an on-demand live stream to start first
+	startTimeout = 15 * time.Second
+)
+
+// restream sends one camera's live video to its RTSP clients
+type restream struct {
+	server *Server
+	camera string
+	rec    *recorder.Recorder
+
+	ready  chan struct{} // Closed once stream is set up, or err is set
+	err    error
+	stream *gortsplib.ServerStream
+	media  *description.Media
+	encode func(au [][]byte) ([]*rtp.Packet, error)
+
+	mu          sync.Mutex
+	readers     map[*gortsplib.ServerSession]string // Session -> client
+	closedBytes uint64                              // Sent to clients that left
+	lastBytes   uint64
+	bitrate     float64 // kbit/s over the last keepAliveInterval
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+func newRestream(server *Server, camera string, rec *recorder.Recorder) *restream {
+	return &restream{
+		server:  server,
+		camera:  camera,
+		rec:     rec,
+		ready:   make(chan struct{}),
+		readers: make(map[*gortsplib.ServerSession]string),
+		done:    make(chan struct{}),
+	}
+}
+
+// wait blocks until the re-stream is set up
+func (rs *restream) wait() error {
+	<-rs.ready
+	return rs.err
+}
+
+// start sets up the stream from the camera's first keyframe, then keeps
+// sending frames until the re-stream stops
+func (rs *restream) start(client string) {
+	segmenter, ok := rs.rec.Segmenter(recorder.PipelineLive)
+	if !ok {
+		rs.fail(errors.New("camera has no live stream"))
+		return
+	}
+
+	// Starts the live stream if it runs on demand
+	rs.rec.RequestStream(recorder.PipelineLive, client)
+
+	frames, unsubscribe := segmenter.Subscribe(frameBuffer)
+	defer unsubscribe()
+
+	var first hls.Frame
+	select {
+	case first = <-frames:
+	case <-time.After(startTimeout):
+		rs.fail(errors.New("timed out waiting for video"))
+		return
+	case <-rs.done:
+		rs.fail(errors.New("server stopped"))
+		return
+	}
+
+	forma, err := videoFormat(segmenter.VideoCodec(), first.Data)
+	if err != nil {
+		rs.fail(err)
+		return
+	}
+
+	switch f := forma.(type) {
+	case *format.H264:
+		enc, err := f.CreateEncoder()
+		if err != nil {
+			rs.fail(fmt.Errorf("creating H.264 encoder: %w", err))
+			return
+		}
+		rs.encode = enc.Encode
+	case *format.H265:
+		enc, err := f.CreateEncoder()
+		if err != nil {
+			rs.fail(fmt.Errorf("creating H.265 encoder: %w", err))
+			return
+		}
+		rs.encode = enc.Encode
+	}
+
+	rs.media = &description.Media{Type: description.MediaTypeVideo, Formats: []format.Format{forma}}
+	rs.stream = gortsplib.NewServerStream(rs.server.server, &description.Session{
+		Title:  rs.camera,
+		Medias: []*description.Media{rs.media},
+	})
+	close(rs.ready)
+
+	rs.run(frames, first)
+}
+
+// fail ends a re-stream that couldn't be set up
+func (rs *restream) fail(err error) {
+	rs.err = err
+	close(rs.ready)
+	rs.server.remove(rs)
+}
+
+// run sends frames to the clients until the re-stream goes idle, the camera
+// goes away or the server stops
+func (rs *restream) run(frames <-chan hls.Frame, first hls.Frame) {
+	defer rs.stream.Close()
+	defer rs.server.remove(rs)
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	rs.write(first)
+	var idle time.Duration
+
+	for {
+		select {
+		case <-rs.done:
+			return
+
+		case <-ticker.C:
+			clients := rs.tick()
+			for _, client := range clients {
+				rs.rec.RequestStream(recorder.PipelineLive, client)
+			}
+
+			if len(clients) > 0 {
+				idle = 0
+			} else if idle += keepAliveInterval; idle >= idleTimeout {
+				return
+			}
+
+			// Cameras that were removed or restarted have a new recorder
+			if rec, ok := rs.server.cameras.Recorder(rs.camera); !ok || rec != rs.rec {
+				return
+			}
+
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			rs.write(frame)
+		}
+	}
+}
+
+// write sends one frame to the clients as RTP packets
+func (rs *restream) write(frame hls.Frame) {
+	nalus, err := h264.AnnexBUnmarshal(frame.Data)
+	if err != nil {
+		return
+	}
+
+	// Access unit delimiters have no use in RTP
+	au := nalus[:0]
+	for _, nalu := range nalus {
+		if len(nalu) == 0 || isDelimiter(rs.media.Formats[0], nalu) {
+			continue
+		}
+		au = append(au, nalu)
+	}
+	if len(au) == 0 {
+		return
+	}
+
+	packets, err := rs.encode(au)
+	if err != nil {
+		return
+	}
+
+	// RTP video uses the same 90kHz clock as MPEG-TS
+	now := time.Now()
+	for _, pkt := range packets {
+		pkt.Timestamp = uint32(frame.PTS)
+		rs.stream.WritePacketRTPWithNTP(rs.media, pkt, now)
+	}
+}
+
+// close stops the re-stream, disconnecting its clients
+func (rs *restream) close() {
+	rs.closeOnce.Do(func() { close(rs.done) })
+}
+
+// addReader registers a client that started playing
+func (rs *restream) addReader(session *gortsplib.ServerSession, client string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.readers[session] = client
+}
+
+// removeReader forgets a client session, returning its name if it was
+// playing this re-stream
+func (rs *restream) removeReader(session *gortsplib.ServerSession) (string, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	client, ok := rs.readers[session]
+	if ok {
+		delete(rs.readers, session)
+		rs.closedBytes += session.BytesSent()
+	}
+	return client, ok
+}
+
+// tick updates the bitrate and returns the clients currently playing
+func (rs *restream) tick() []string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	clients := make([]string, 0, len(rs.readers))
+	for _, client := range rs.readers {
+		clients = append(clients, client)
+	}
+
+	sent := rs.bytesSent()
+	rs.bitrate = float64(sent-rs.lastBytes) * 8 / 1000 / keepAliveInterval.Seconds()
+	rs.lastBytes = sent
+	return clients
+}
+
+// stats returns the re-stream's statistics
+func (rs *restream) stats() Stats {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return Stats{
+		Clients:     len(rs.readers),
+		BytesSent:   rs.bytesSent(),
+		BitrateKbps: rs.bitrate,
+	}
+}
+
+// bytesSent totals the bytes sent to all clients. Caller must hold rs.mu.
+func (rs *restream) bytesSent() uint64 {
+	sent := rs.closedBytes
+	for session := range rs.readers {
+		sent += session.BytesSent()
+	}
+	return sent
+}
+
+// videoFormat describes the stream from its codec and the parameter sets
+// in a keyframe
+func videoFormat(codec string, keyframe []byte) (format.Format, error) {
+	nalus, err := h264.AnnexBUnmarshal(keyframe)
+	if err != nil {
+		return nil, fmt.Errorf("reading keyframe: %w", err)
+	}
+
+	switch codec {
+	case hls.CodecH264:
+		forma := &format.H264{PayloadTyp: 96, PacketizationMode: 1}
+		for _, nalu := range nalus {
+			if len(nalu) == 0 {
+				continue
+			}
+			switch h264.NALUType(nalu[0] & 0x1F) {
+			case h264.NALUTypeSPS:
+				forma.SPS = nalu
+			case h264.NALUTypePPS:
+				forma.PPS = nalu
+			}
+		}
+		if forma.SPS == nil || forma.PPS == nil {
+			return nil, errors.New("keyframe has no SPS/PPS")
+		}
+		return forma, nil
+
+	case hls.CodecHEVC:
+		forma := &format.H265{PayloadTyp: 96}
+		for _, nalu := range nalus {
+			if len(nalu) < 2 {
+				continue
+			}
+			switch h265.NALUType((nalu[0] >> 1) & 0x3F) {
+			case h265.NALUType_VPS_NUT:
+				forma.VPS = nalu
+			case h265.NALUType_SPS_NUT:
+				forma.SPS = nalu
+			case h265.NALUType_PPS_NUT:
+				forma.PPS = nalu
+			}
+		}
+		if forma.VPS == nil || forma.SPS == nil || forma.PPS == nil {
+			return nil, errors.New("keyframe has no VPS/SPS/PPS")
+		}
+		return forma, nil
+	}
+
+	return nil, fmt.Errorf("unsupported video codec %q", codec)
+}
+
+// isDelimiter reports whether a NAL unit is an access unit delimiter
+func isDelimiter(forma format.Format, nalu []byte) bool {
+	if _, ok := forma.(*format.H265); ok {
+		return h265.NALUType((nalu[0]>>1)&0x3F) == h265.NALUType_AUD_NUT
+	}
+	return h264.NALUType(nalu[0]&0x1F) == h264.NALUTypeAccessUnitDelimiter
+}
@@ -0,0 +1,232 @@
+// Package rtsp re-streams each camera's live video over RTSP, so other
+// systems on the network don't need their own camera connections
+package rtsp
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/headers"
+
+	"github.com/mmuteeullah/CoreNVR/internal/auth"
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/supervisor"
+)
+
+// Stats describes one camera's re-stream
+type Stats struct {
+	Clients     int     `json:"clients"`
+	BytesSent   uint64  `json:"bytes_sent"`
+	BitrateKbps float64 `json:"bitrate_kbps"`
+}
+
+// Server serves rtsp://host:port/<camera> from the cameras' live pipelines
+type Server struct {
+	cfg      config.RTSPConfig
+	auth     config.AuthConfig
+	cameras  *supervisor.Supervisor
+	server   *gortsplib.Server
+	logger   *log.Logger
+	mu       sync.Mutex
+	streams  map[string]*restream // Camera -> running re-stream
+	verified sync.Map             // Authorization headers that passed
+}
+
+// NewServer creates an RTSP server for the cameras. When webui
+// authentication is enabled, clients must log in with the same credentials.
+func NewServer(cfg config.RTSPConfig, authCfg config.AuthConfig, cameras *supervisor.Supervisor) *Server {
+	if cfg.Port == 0 {
+		cfg.Port = 8554
+	}
+	if cfg.UDPPort == 0 {
+		cfg.UDPPort = 8000
+	}
+
+	s := &Server{
+		cfg:     cfg,
+		auth:    authCfg,
+		cameras: cameras,
+		logger:  log.New(os.Stdout, "[RTSP] ", log.LstdFlags),
+		streams: make(map[string]*restream),
+	}
+
+	s.server = &gortsplib.Server{
+		Handler:     s,
+		RTSPAddress: fmt.Sprintf(":%d", cfg.Port),
+	}
+	if cfg.UDPPort > 0 {
+		s.server.UDPRTPAddress = fmt.Sprintf(":%d", cfg.UDPPort)
+		s.server.UDPRTCPAddress = fmt.Sprintf(":%d", cfg.UDPPort+1)
+	}
+	return s
+}
+
+// Start begins accepting RTSP clients
+func (s *Server) Start() error {
+	if err := s.server.Start(); err != nil {
+		return fmt.Errorf("starting RTSP server: %w", err)
+	}
+	s.logger.Printf("📡 RTSP re-streaming on rtsp://0.0.0.0:%d/<camera>", s.cfg.Port)
+	return nil
+}
+
+// Stop disconnects all clients and stops the server
+func (s *Server) Stop() {
+	s.server.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rs := range s.streams {
+		rs.close()
+	}
+}
+
+// Port returns the RTSP port
+func (s *Server) Port() int {
+	return s.cfg.Port
+}
+
+// Stats returns a camera's re-stream statistics. Cameras nobody is
+// watching over RTSP report zeros.
+func (s *Server) Stats(camera string) Stats {
+	s.mu.Lock()
+	rs, ok := s.streams[camera]
+	s.mu.Unlock()
+	if !ok {
+		return Stats{}
+	}
+	return rs.stats()
+}
+
+// OnSessionClose removes a client from the re-stream it was watching
+func (s *Server) OnSessionClose(ctx *gortsplib.ServerHandlerOnSessionCloseCtx) {
+	s.mu.Lock()
+	streams := make([]*restream, 0, len(s.streams))
+	for _, rs := range s.streams {
+		streams = append(streams, rs)
+	}
+	s.mu.Unlock()
+
+	for _, rs := range streams {
+		if client, ok := rs.removeReader(ctx.Session); ok {
+			s.logger.Printf("⏹️  %s stopped watching %s", client, rs.camera)
+		}
+	}
+}
+
+// OnDescribe returns the stream description of a camera, starting its
+// re-stream if needed
+func (s *Server) OnDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx) (*base.Response, *gortsplib.ServerStream, error) {
+	if res := s.authenticate(ctx.Request); res != nil {
+		return res, nil, nil
+	}
+
+	rs, res := s.restream(ctx.Path, clientName(ctx.Conn))
+	if res != nil {
+		return res, nil, nil
+	}
+	return &base.Response{StatusCode: base.StatusOK}, rs.stream, nil
+}
+
+// OnSetup prepares a client session for a camera's stream
+func (s *Server) OnSetup(ctx *gortsplib.ServerHandlerOnSetupCtx) (*base.Response, *gortsplib.ServerStream, error) {
+	if res := s.authenticate(ctx.Request); res != nil {
+		return res, nil, nil
+	}
+
+	rs, res := s.restream(ctx.Path, clientName(ctx.Conn))
+	if res != nil {
+		return res, nil, nil
+	}
+	return &base.Response{StatusCode: base.StatusOK}, rs.stream, nil
+}
+
+// OnPlay starts sending a camera's stream to a client
+func (s *Server) OnPlay(ctx *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, error) {
+	if res := s.authenticate(ctx.Request); res != nil {
+		return res, nil
+	}
+
+	client := clientName(ctx.Conn)
+	rs, res := s.restream(ctx.Path, client)
+	if res != nil {
+		return res, nil
+	}
+
+	rs.addReader(ctx.Session, client)
+	s.logger.Printf("▶️  %s watching %s (%s)", client, rs.camera, ctx.Session.SetuppedTransport())
+	return &base.Response{StatusCode: base.StatusOK}, nil
+}
+
+// restream returns the running re-stream of the camera at path, starting
+// it if needed, or an error response
+func (s *Server) restream(path, client string) (*restream, *base.Response) {
+	camera := strings.Trim(path, "/")
+
+	rec, ok := s.cameras.Recorder(camera)
+	if !ok {
+		return nil, &base.Response{StatusCode: base.StatusNotFound}
+	}
+
+	s.mu.Lock()
+	rs, ok := s.streams[camera]
+	if !ok {
+		rs = newRestream(s, camera, rec)
+		s.streams[camera] = rs
+		go rs.start(client)
+	}
+	s.mu.Unlock()
+
+	if err := rs.wait(); err != nil {
+		s.logger.Printf("❌ Can't re-stream %s: %v", camera, err)
+		return nil, &base.Response{StatusCode: base.StatusServiceUnavailable}
+	}
+	return rs, nil
+}
+
+// remove forgets a re-stream that stopped
+func (s *Server) remove(rs *restream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streams[rs.camera] == rs {
+		delete(s.streams, rs.camera)
+	}
+}
+
+// authenticate checks a request's Basic credentials against the webui
+// login. It returns nil if the request may proceed.
+func (s *Server) authenticate(req *base.Request) *base.Response {
+	if !s.auth.Enabled {
+		return nil
+	}
+
+	// bcrypt is slow, and clients send the same header with every request
+	header := strings.Join(req.Header["Authorization"], ",")
+	if _, ok := s.verified.Load(header); ok {
+		return nil
+	}
+
+	var authz headers.Authorization
+	if err := authz.Unmarshal(req.Header["Authorization"]); err == nil && authz.Method == headers.AuthBasic &&
+		auth.CheckPassword(s.auth.Username, s.auth.PasswordHash, authz.BasicUser, authz.BasicPass) {
+		s.verified.Store(header, struct{}{})
+		return nil
+	}
+
+	return &base.Response{
+		StatusCode: base.StatusUnauthorized,
+		Header: base.Header{
+			"WWW-Authenticate": base.HeaderValue{`Basic realm="CoreNVR"`},
+		},
+	}
+}
+
+// clientName identifies an RTSP client by its address
+func clientName(conn *gortsplib.ServerConn) string {
+	return "rtsp " + conn.NetConn().RemoteAddr().String()
+}
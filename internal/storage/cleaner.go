@@ -8,9 +8,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/mmuteeullah/CoreNVR/internal/catalog"
 	"github.com/mmuteeullah/CoreNVR/internal/config"
 )
 
@@ -24,17 +27,19 @@ const (
 
 // Cleaner handles deletion of old recordings
 type Cleaner struct {
-	config config.StorageConfig
-	logger *log.Logger
+	config  config.StorageConfig
+	catalog *catalog.Catalog
+	logger  *log.Logger
 	lastAlertLevel int
 	lastAlertTime  time.Time
 	slackWebhook   string
 }
 
 // NewCleaner creates a new storage cleaner
-func NewCleaner(cfg config.StorageConfig, slackWebhook string) *Cleaner {
+func NewCleaner(cfg config.StorageConfig, slackWebhook string, cat *catalog.Catalog) *Cleaner {
 	return &Cleaner{
 		config: cfg,
+		catalog: cat,
 		logger: log.New(os.Stdout, "[Storage] ", log.LstdFlags),
 		slackWebhook: slackWebhook,
 		lastAlertLevel: DiskAlertNone,
@@ -77,47 +82,47 @@ func (c *Cleaner) cleanup() {
 	deletedDirs := 0
 	freedBytes := int64(0)
 
-	// Walk through the base directory
-	err := filepath.Walk(c.config.BasePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Continue with other files
+	// Look up date directories in the catalog, plus any on disk it doesn't
+	// know, such as empty ones or ones holding only broken segments
+	for _, dir := range c.recordingDirs() {
+		if !dir.date.Before(cutoffTime) {
+			continue
 		}
 
-		// Skip the base directory itself
-		if path == c.config.BasePath {
-			return nil
+		if err := c.deleteDateDir(dir); err != nil {
+			c.logger.Printf("Failed to delete %s: %v", dir.path, err)
+		} else {
+			deletedDirs++
+			freedBytes += dir.size
+			c.logger.Printf("Deleted old directory: %s", dir.path)
 		}
+	}
 
-		// Check if this is a date directory (YYYY-MM-DD format)
-		if info.IsDir() {
-			dirName := filepath.Base(path)
-			if len(dirName) == 10 && dirName[4] == '-' && dirName[7] == '-' {
-				// Parse the date
-				dirDate, err := time.Parse("2006-01-02", dirName)
-				if err == nil && dirDate.Before(cutoffTime) {
-					// Calculate size before deletion
-					size := c.getDirSize(path)
-
-					// Delete the entire directory
-					if err := os.RemoveAll(path); err != nil {
-						c.logger.Printf("Failed to delete %s: %v", path, err)
-					} else {
-						deletedDirs++
-						freedBytes += size
-						c.logger.Printf("Deleted old directory: %s", path)
-					}
-
-					// Skip walking into this directory
-					return filepath.SkipDir
-				}
-			}
+	// Snapshot folders of days without recordings aren't in the catalog
+	for _, dir := range c.diskDateDirs("snapshots") {
+		if !dir.date.Before(cutoffTime) {
+			continue
 		}
 
-		return nil
-	})
+		if err := os.RemoveAll(dir.path); err != nil {
+			c.logger.Printf("Failed to delete %s: %v", dir.path, err)
+		} else {
+			deletedDirs++
+			freedBytes += dir.size
+			c.logger.Printf("Deleted old directory: %s", dir.path)
+		}
+	}
 
-	if err != nil {
-		c.logger.Printf("Cleanup error: %v", err)
+	// Events are kept as long as recordings; their files are small enough
+	// not to count towards the space freed
+	for _, path := range c.eventFiles() {
+		date, err := time.ParseInLocation("2006-01-02", strings.TrimSuffix(filepath.Base(path), ".jsonl"), time.Local)
+		if err != nil || !date.Before(cutoffTime) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			c.logger.Printf("Failed to delete %s: %v", path, err)
+		}
 	}
 
 	if deletedDirs > 0 {
@@ -128,21 +133,117 @@ func (c *Cleaner) cleanup() {
 	}
 }
 
-// getDirSize calculates the total size of a directory
-func (c *Cleaner) getDirSize(path string) int64 {
-	var size int64
-	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+// dateDir is a camera's recordings folder for one day
+type dateDir struct {
+	camera string
+	name   string
+	path   string
+	date   time.Time
+	size   int64
+}
+
+// dateDirs returns every cataloged date directory, oldest first
+func (c *Cleaner) dateDirs() []dateDir {
+	var dirs []dateDir
+	for _, camera := range c.catalog.Cameras() {
+		for _, name := range c.catalog.Dates(camera) {
+			date, err := time.ParseInLocation("2006-01-02", name, time.Local)
+			if err != nil {
+				continue
+			}
+			dirs = append(dirs, dateDir{
+				camera: camera,
+				name:   name,
+				path:   filepath.Join(c.config.BasePath, camera, "recordings", name),
+				date:   date,
+				size:   c.catalog.Size(camera, name),
+			})
+		}
+	}
+
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return dirs[i].date.Before(dirs[j].date)
+	})
+	return dirs
+}
+
+// recordingDirs returns every recordings date directory, oldest first: the
+// cataloged ones and those on disk the catalog doesn't know
+func (c *Cleaner) recordingDirs() []dateDir {
+	dirs := c.dateDirs()
+	cataloged := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		cataloged[dir.path] = true
+	}
+	for _, dir := range c.diskDateDirs("recordings") {
+		if !cataloged[dir.path] {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return dirs[i].date.Before(dirs[j].date)
+	})
+	return dirs
+}
+
+// diskDateDirs returns every camera's date directories on disk in a folder
+// such as snapshots
+func (c *Cleaner) diskDateDirs(folder string) []dateDir {
+	paths, _ := filepath.Glob(filepath.Join(c.config.BasePath, "*", folder, "*"))
+
+	var dirs []dateDir
+	for _, path := range paths {
+		name := filepath.Base(path)
+		date, err := time.ParseInLocation("2006-01-02", name, time.Local)
 		if err != nil {
-			return err
+			continue
 		}
-		if !info.IsDir() {
+		dirs = append(dirs, dateDir{
+			camera: filepath.Base(filepath.Dir(filepath.Dir(path))),
+			name:   name,
+			path:   path,
+			date:   date,
+			size:   dirSize(path),
+		})
+	}
+	return dirs
+}
+
+// eventFiles returns every camera's daily event files
+func (c *Cleaner) eventFiles() []string {
+	paths, _ := filepath.Glob(filepath.Join(c.config.BasePath, "*", "events", "*.jsonl"))
+	return paths
+}
+
+// dirSize adds up the sizes of the files in a directory
+func dirSize(path string) int64 {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return 0
+	}
+
+	var size int64
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !info.IsDir() {
 			size += info.Size()
 		}
-		return nil
-	})
+	}
 	return size
 }
 
+// deleteDateDir removes a date directory from disk and from the catalog,
+// along with the camera's snapshots of that day
+func (c *Cleaner) deleteDateDir(dir dateDir) error {
+	if err := os.RemoveAll(dir.path); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(c.config.BasePath, dir.camera, "snapshots", dir.name)); err != nil {
+		return err
+	}
+	return c.catalog.RemoveDate(dir.camera, dir.name)
+}
+
 // GetDiskUsage returns current disk usage statistics
 func GetDiskUsage(path string) (used, available uint64, percentUsed float64, err error) {
 	var stat syscall.Statfs_t
@@ -218,38 +319,8 @@ func (c *Cleaner) emergencyCleanup(currentAvailableGB float64) {
 	total := stat.Blocks * uint64(stat.Bsize)
 	targetFree := float64(total) * 0.10 // 10% free
 
-	// Find all date directories
-	type DirInfo struct {
-		path string
-		date time.Time
-		size int64
-	}
-
-	var dirs []DirInfo
-	filepath.Walk(c.config.BasePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil || path == c.config.BasePath || !info.IsDir() {
-			return nil
-		}
-
-		dirName := filepath.Base(path)
-		if len(dirName) == 10 && dirName[4] == '-' && dirName[7] == '-' {
-			if dirDate, err := time.Parse("2006-01-02", dirName); err == nil {
-				size := c.getDirSize(path)
-				dirs = append(dirs, DirInfo{path: path, date: dirDate, size: size})
-				return filepath.SkipDir
-			}
-		}
-		return nil
-	})
-
-	// Sort by date (oldest first)
-	for i := 0; i < len(dirs)-1; i++ {
-		for j := i + 1; j < len(dirs); j++ {
-			if dirs[i].date.After(dirs[j].date) {
-				dirs[i], dirs[j] = dirs[j], dirs[i]
-			}
-		}
-	}
+	// Find all date directories (oldest first)
+	dirs := c.recordingDirs()
 
 	// Delete oldest directories until we have enough space
 	freedBytes := int64(0)
@@ -271,7 +342,7 @@ func (c *Cleaner) emergencyCleanup(currentAvailableGB float64) {
 		}
 
 		c.logger.Printf("Emergency deleting: %s (%.2f GB)", dir.path, float64(dir.size)/(1024*1024*1024))
-		if err := os.RemoveAll(dir.path); err != nil {
+		if err := c.deleteDateDir(dir); err != nil {
 			c.logger.Printf("Failed to delete %s: %v", dir.path, err)
 		} else {
 			freedBytes += dir.size
@@ -0,0 +1,364 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/catalog"
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/recorder"
+)
+
+// recorderExitTimeout bounds how long a camera change waits for the old
+// recorder to finish before starting the new one
+const recorderExitTimeout = 30 * time.Second
+
+// Errors returned by camera management
+var (
+	ErrCameraExists   = errors.New("camera already exists")
+	ErrCameraNotFound = errors.New("camera not found")
+	ErrCameraRenamed  = errors.New("camera cannot be renamed")
+	ErrInvalidConfig  = errors.New("invalid config")
+)
+
+// Watcher is notified when recorders are added or removed at runtime
+type Watcher interface {
+	AddRecorder(rec *recorder.Recorder)
+	RemoveRecorder(name string)
+}
+
+// Supervisor owns the running recorders and the cameras section of the
+// configuration, so cameras can be added, changed and removed at runtime
+type Supervisor struct {
+	cfg        *config.Config
+	configPath string
+	catalog    *catalog.Catalog
+	logger     *log.Logger
+	ctx        context.Context
+	recorders  map[string]*recorder.Recorder
+	cancels    map[string]context.CancelFunc // Cancels a recorder that hasn't started yet
+	exited     map[string]chan struct{}      // Closed once a recorder has fully stopped
+	watchers   []Watcher
+	wg         sync.WaitGroup
+	mu         sync.RWMutex
+	changeMu   sync.Mutex // Serializes camera changes, which stop recorders without holding mu
+}
+
+// New creates a supervisor for the cameras in cfg. Changes are persisted to
+// the config file at configPath.
+func New(ctx context.Context, cfg *config.Config, configPath string, cat *catalog.Catalog) *Supervisor {
+	return &Supervisor{
+		cfg:        cfg,
+		configPath: configPath,
+		catalog:    cat,
+		logger:     log.New(os.Stdout, "[Supervisor] ", log.LstdFlags),
+		ctx:        ctx,
+		recorders:  make(map[string]*recorder.Recorder),
+		cancels:    make(map[string]context.CancelFunc),
+		exited:     make(map[string]chan struct{}),
+	}
+}
+
+// StartAll starts a recorder for every enabled camera, stagger apart so they
+// don't all connect at once
+func (s *Supervisor) StartAll(stagger time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	started := 0
+	for _, cam := range s.cfg.Cameras {
+		if !cam.Enabled {
+			s.logger.Printf("Camera %s is disabled, skipping", cam.Name)
+			continue
+		}
+
+		s.startRecorder(cam, stagger*time.Duration(started))
+		started++
+	}
+}
+
+// Watch registers a watcher for recorder changes
+func (s *Supervisor) Watch(w Watcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watchers = append(s.watchers, w)
+}
+
+// Recorders returns the running recorders
+func (s *Supervisor) Recorders() []*recorder.Recorder {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	recorders := make([]*recorder.Recorder, 0, len(s.recorders))
+	for _, cam := range s.cfg.Cameras {
+		if rec, ok := s.recorders[cam.Name]; ok {
+			recorders = append(recorders, rec)
+		}
+	}
+	return recorders
+}
+
+// Recorder returns the running recorder of a camera
+func (s *Supervisor) Recorder(name string) (*recorder.Recorder, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.recorders[name]
+	return rec, ok
+}
+
+// Cameras returns a copy of the configured cameras
+func (s *Supervisor) Cameras() []config.CameraConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cameras := make([]config.CameraConfig, len(s.cfg.Cameras))
+	for i, cam := range s.cfg.Cameras {
+		cameras[i] = cam.Clone()
+	}
+	return cameras
+}
+
+// Camera returns a copy of a camera's configuration, which callers may
+// change freely
+func (s *Supervisor) Camera(name string) (config.CameraConfig, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.find(name)
+	if i < 0 {
+		return config.CameraConfig{}, false
+	}
+	return s.cfg.Cameras[i].Clone(), true
+}
+
+// AddCamera adds a camera, saves the config and starts recording if it's enabled
+func (s *Supervisor) AddCamera(cam config.CameraConfig) error {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(cam.Name) >= 0 {
+		return ErrCameraExists
+	}
+
+	cameras := append(append([]config.CameraConfig(nil), s.cfg.Cameras...), cam)
+	if err := s.apply(cameras); err != nil {
+		return err
+	}
+
+	s.logger.Printf("➕ Camera %s added", cam.Name)
+
+	// Recordings may exist from an earlier camera with the same name
+	go func() {
+		if err := s.catalog.Sync(cam.Name); err != nil {
+			s.logger.Printf("WARNING: Failed to sync catalog for %s: %v", cam.Name, err)
+		}
+	}()
+
+	if cam.Enabled {
+		s.startRecorder(cam, 0)
+	}
+	return nil
+}
+
+// UpdateCamera replaces a camera's settings, saves the config and restarts
+// its recorder with the new settings
+func (s *Supervisor) UpdateCamera(name string, cam config.CameraConfig) error {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
+	s.mu.Lock()
+	i := s.find(name)
+	if i < 0 {
+		s.mu.Unlock()
+		return ErrCameraNotFound
+	}
+	if cam.Name != name {
+		// Recordings are stored under the camera name
+		s.mu.Unlock()
+		return ErrCameraRenamed
+	}
+
+	cameras := append([]config.CameraConfig(nil), s.cfg.Cameras...)
+	cameras[i] = cam
+	if err := s.apply(cameras); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	s.logger.Printf("✏️  Camera %s updated", name)
+	old, exited := s.detachRecorder(name)
+	s.mu.Unlock()
+
+	// Stopping waits for FFmpeg, so readers aren't held up meanwhile. The
+	// old recorder must be done with the camera's segments before the new
+	// one starts.
+	if old != nil {
+		s.stopRecorder(name, old, exited)
+	}
+
+	if cam.Enabled {
+		s.mu.Lock()
+		s.startRecorder(cam, 0)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// RemoveCamera stops a camera's recorder and removes it from the config.
+// Its recordings are kept and expire through normal retention.
+func (s *Supervisor) RemoveCamera(name string) error {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
+	s.mu.Lock()
+	i := s.find(name)
+	if i < 0 {
+		s.mu.Unlock()
+		return ErrCameraNotFound
+	}
+
+	cameras := append([]config.CameraConfig(nil), s.cfg.Cameras[:i]...)
+	cameras = append(cameras, s.cfg.Cameras[i+1:]...)
+	if err := s.apply(cameras); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	s.logger.Printf("➖ Camera %s removed", name)
+	old, exited := s.detachRecorder(name)
+	s.mu.Unlock()
+
+	if old != nil {
+		s.stopRecorder(name, old, exited)
+	}
+	return nil
+}
+
+// StopAll stops every recorder and waits up to timeout for them to exit.
+// It reports whether they all stopped in time.
+func (s *Supervisor) StopAll(timeout time.Duration) bool {
+	s.mu.Lock()
+	recorders := make([]*recorder.Recorder, 0, len(s.recorders))
+	for name, rec := range s.recorders {
+		s.cancels[name]()
+		recorders = append(recorders, rec)
+	}
+	s.mu.Unlock()
+
+	for _, rec := range recorders {
+		rec.Stop()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// apply validates a new camera list, persists it and makes it current.
+// Caller must hold s.mu.
+func (s *Supervisor) apply(cameras []config.CameraConfig) error {
+	// Validate the whole config so the saved file will load on next start
+	candidate := *s.cfg
+	candidate.Cameras = cameras
+	if err := candidate.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	if err := config.SaveCameras(s.configPath, cameras); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+
+	s.cfg.Cameras = cameras
+	return nil
+}
+
+// startRecorder creates and starts a camera's recorder after delay.
+// Caller must hold s.mu.
+func (s *Supervisor) startRecorder(cam config.CameraConfig, delay time.Duration) {
+	s.logger.Printf("Starting recorder for camera: %s", cam.Name)
+
+	rec := recorder.New(cam, s.cfg.Storage, s.catalog)
+	ctx, cancel := context.WithCancel(s.ctx)
+	exited := make(chan struct{})
+	s.recorders[cam.Name] = rec
+	s.cancels[cam.Name] = cancel
+	s.exited[cam.Name] = exited
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		rec.Start(ctx)
+	}()
+
+	for _, w := range s.watchers {
+		w.AddRecorder(rec)
+	}
+}
+
+// detachRecorder cancels and forgets a camera's recorder, and returns it
+// with a channel that closes once it has exited, so the caller can stop it
+// once s.mu is released. It returns nil if the camera has no recorder.
+// Caller must hold s.mu.
+func (s *Supervisor) detachRecorder(name string) (*recorder.Recorder, <-chan struct{}) {
+	rec, ok := s.recorders[name]
+	if !ok {
+		return nil, nil
+	}
+
+	for _, w := range s.watchers {
+		w.RemoveRecorder(name)
+	}
+
+	exited := s.exited[name]
+	s.cancels[name]()
+	delete(s.cancels, name)
+	delete(s.exited, name)
+	delete(s.recorders, name)
+	return rec, exited
+}
+
+// stopRecorder stops a detached recorder and waits for it to exit. Caller
+// must not hold s.mu.
+func (s *Supervisor) stopRecorder(name string, rec *recorder.Recorder, exited <-chan struct{}) {
+	rec.Stop()
+
+	select {
+	case <-exited:
+	case <-time.After(recorderExitTimeout):
+		s.logger.Printf("WARNING: Recorder for %s didn't stop within %v", name, recorderExitTimeout)
+	}
+}
+
+// find returns the index of a camera in the config, or -1. Caller must hold s.mu.
+func (s *Supervisor) find(name string) int {
+	for i, cam := range s.cfg.Cameras {
+		if cam.Name == name {
+			return i
+		}
+	}
+	return -1
+}
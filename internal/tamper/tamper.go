@@ -0,0 +1,161 @@
+// Package tamper notices when a camera stops showing its scene: the image
+// goes black, is covered, freezes, or the video stops altogether
+package tamper
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Reasons for tampering
+const (
+	ReasonBlack   = "black"    // Image is dark and featureless
+	ReasonCovered = "covered"  // Image is featureless but not dark, such as paint or a wall up close
+	ReasonFrozen  = "frozen"   // Image doesn't change at all
+	ReasonNoVideo = "no_video" // No video arrives
+)
+
+const (
+	// blankStdDev is the brightness spread below which an image counts as
+	// featureless. Real scenes, even at night, spread far wider.
+	blankStdDev = 6.0
+
+	// blackLevel is the mean brightness below which a featureless image
+	// counts as black rather than covered
+	blackLevel = 32.0
+
+	// frozenDiff is the mean brightness change between frames below which
+	// the image counts as frozen. Sensor noise alone changes a live image
+	// more than this.
+	frozenDiff = 0.05
+
+	defaultHoldTime = 30 * time.Second
+)
+
+// State is a camera's tamper state
+type State struct {
+	Tampered bool      `json:"tampered"`
+	Reason   string    `json:"reason,omitempty"`
+	Since    time.Time `json:"since,omitempty"` // When the condition was first seen
+}
+
+// Detector turns grayscale frames, one byte per pixel, into a tamper state.
+// A condition must last for the hold time to count as tampering, so brief
+// glitches and lights switching off for a moment don't.
+type Detector struct {
+	hold time.Duration
+
+	mu          sync.Mutex
+	prev        []byte
+	lastFrameAt time.Time
+	condition   string // Condition of the latest frame, "" if it looked fine
+	since       time.Time
+	state       State
+}
+
+// NewDetector creates a detector that reports conditions lasting hold. Zero
+// uses the default of 30 seconds.
+func NewDetector(hold time.Duration) *Detector {
+	if hold <= 0 {
+		hold = defaultHoldTime
+	}
+	return &Detector{hold: hold, lastFrameAt: time.Now()}
+}
+
+// Feed checks a frame. It returns the new state when it changes.
+func (d *Detector) Feed(frame []byte, now time.Time) (State, bool) {
+	if len(frame) == 0 {
+		return State{}, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	prev := d.prev
+	d.prev = append(d.prev[:0:0], frame...)
+	d.lastFrameAt = now
+
+	condition := ""
+	mean, stdDev := brightness(frame)
+	switch {
+	case stdDev < blankStdDev && mean < blackLevel:
+		condition = ReasonBlack
+	case stdDev < blankStdDev:
+		condition = ReasonCovered
+	case len(prev) == len(frame) && meanDiff(prev, frame) < frozenDiff:
+		condition = ReasonFrozen
+	}
+	return d.observe(condition, now)
+}
+
+// Check reports video loss once no frame has arrived for the hold time. It
+// returns the new state when it changes.
+func (d *Detector) Check(now time.Time) (State, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.lastFrameAt) < d.hold {
+		return State{}, false
+	}
+	if d.condition != ReasonNoVideo {
+		// The hold time has already passed since the last frame
+		d.condition = ReasonNoVideo
+		d.since = d.lastFrameAt
+		d.prev = nil
+	}
+	return d.observe(ReasonNoVideo, now)
+}
+
+// observe records the current condition and updates the state. Caller must
+// hold d.mu.
+func (d *Detector) observe(condition string, now time.Time) (State, bool) {
+	if condition != d.condition {
+		d.condition = condition
+		d.since = now
+	}
+
+	state := State{}
+	switch {
+	case condition == "":
+	case d.state.Tampered && d.state.Reason == condition:
+		state = d.state
+	case now.Sub(d.since) >= d.hold:
+		state = State{Tampered: true, Reason: condition, Since: d.since}
+	default:
+		// Not held long enough yet; an earlier tamper still stands
+		state = d.state
+	}
+
+	if state == d.state {
+		return State{}, false
+	}
+	d.state = state
+	return state, true
+}
+
+// brightness returns the mean and standard deviation of a frame's pixels
+func brightness(frame []byte) (float64, float64) {
+	var sum, sumSquares float64
+	for _, p := range frame {
+		v := float64(p)
+		sum += v
+		sumSquares += v * v
+	}
+	n := float64(len(frame))
+	mean := sum / n
+	return mean, math.Sqrt(math.Max(sumSquares/n-mean*mean, 0))
+}
+
+// meanDiff returns the mean absolute brightness change between two frames
+func meanDiff(a, b []byte) float64 {
+	var total int
+	for i := range a {
+		diff := int(a[i]) - int(b[i])
+		if diff < 0 {
+			diff = -diff
+		}
+		total += diff
+	}
+	return float64(total) / float64(len(a))
+}
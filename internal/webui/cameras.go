@@ -0,0 +1,239 @@
+package webui
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/recorder"
+	"github.com/mmuteeullah/CoreNVR/internal/supervisor"
+)
+
+// maxCameraRequestSize limits camera management request bodies
+const maxCameraRequestSize = 64 << 10
+
+// handleAddCamera adds a camera from a JSON body using the config field names
+// POST /api/cameras
+func (s *Server) handleAddCamera(w http.ResponseWriter, r *http.Request) {
+	// Same defaults as a camera added to the config file by hand
+	cam := config.CameraConfig{
+		Enabled:    true,
+		RetryDelay: 5,
+		MaxRetries: -1,
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCameraRequestSize)).Decode(&cam); err != nil {
+		http.Error(w, "Invalid camera JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.cameras.AddCamera(cam); err != nil {
+		s.cameraError(w, cam.Name, err)
+		return
+	}
+
+	s.logger.Printf("Camera %s added via API", cam.Name)
+	s.writeCameraResult(w, http.StatusCreated, "added", cam.Name)
+}
+
+// handleUpdateCamera changes a camera's settings. Fields missing from the
+// JSON body keep their current values.
+// PUT /api/cameras/{name}
+func (s *Server) handleUpdateCamera(w http.ResponseWriter, r *http.Request, name string) {
+	cam, ok := s.cameras.Camera(name)
+	if !ok {
+		http.Error(w, "Camera not found", http.StatusNotFound)
+		return
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCameraRequestSize)).Decode(&cam); err != nil {
+		http.Error(w, "Invalid camera JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.cameras.UpdateCamera(name, cam); err != nil {
+		s.cameraError(w, name, err)
+		return
+	}
+
+	s.logger.Printf("Camera %s updated via API", name)
+	s.writeCameraResult(w, http.StatusOK, "updated", name)
+}
+
+// handleRemoveCamera stops and removes a camera. Its recordings are kept.
+// DELETE /api/cameras/{name}
+func (s *Server) handleRemoveCamera(w http.ResponseWriter, r *http.Request, name string) {
+	if err := s.cameras.RemoveCamera(name); err != nil {
+		s.cameraError(w, name, err)
+		return
+	}
+
+	s.logger.Printf("Camera %s removed via API", name)
+	s.writeCameraResult(w, http.StatusOK, "removed", name)
+}
+
+// handlePauseCamera pauses a camera's recording, optionally for a number of
+// seconds given as {"duration": N}. Live view keeps running.
+// POST /api/cameras/{name}/pause
+func (s *Server) handlePauseCamera(w http.ResponseWriter, r *http.Request, rec *recorder.Recorder) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Duration int `json:"duration"` // seconds, 0 = until resumed
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCameraRequestSize)).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid pause JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Duration < 0 {
+		http.Error(w, "duration must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	if err := rec.Pause(time.Duration(req.Duration) * time.Second); err != nil {
+		s.logger.Printf("Failed to pause camera %s: %v", rec.GetCameraName(), err)
+		http.Error(w, "Failed to pause camera", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Printf("Camera %s paused via API", rec.GetCameraName())
+	s.writePauseResult(w, rec)
+}
+
+// handleTrigger makes an event-recording camera save a clip around now,
+// optionally naming why as {"reason": "..."}
+// POST /api/cameras/{name}/trigger
+func (s *Server) handleTrigger(w http.ResponseWriter, r *http.Request, rec *recorder.Recorder) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCameraRequestSize)).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid trigger JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "API"
+	}
+
+	if err := rec.Trigger(req.Reason); err != nil {
+		if errors.Is(err, recorder.ErrNotEventMode) || errors.Is(err, recorder.ErrRecordingOff) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to trigger recording", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Printf("Camera %s triggered via API (%s)", rec.GetCameraName(), req.Reason)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"camera":    rec.GetCameraName(),
+		"triggered": true,
+		"reason":    req.Reason,
+	})
+}
+
+// handleResumeCamera ends a camera's pause
+// POST /api/cameras/{name}/resume
+func (s *Server) handleResumeCamera(w http.ResponseWriter, r *http.Request, rec *recorder.Recorder) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := rec.Resume(); err != nil {
+		s.logger.Printf("Failed to resume camera %s: %v", rec.GetCameraName(), err)
+		http.Error(w, "Failed to resume camera", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Printf("Camera %s resumed via API", rec.GetCameraName())
+	s.writePauseResult(w, rec)
+}
+
+// handleSnapshot returns a JPEG of a camera's current view. With ?cached=1
+// it returns the latest snapshot taken instead, or 404 if there is none,
+// which is cheap enough for thumbnails.
+// GET /api/cameras/{name}/snapshot.jpg
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request, rec *recorder.Recorder) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var img []byte
+	if r.URL.Query().Get("cached") == "1" {
+		var ok bool
+		if img, _, ok = rec.LatestSnapshot(); !ok {
+			http.Error(w, "No snapshot yet", http.StatusNotFound)
+			return
+		}
+	} else {
+		var err error
+		if img, err = rec.Snapshot(r.Context()); err != nil {
+			s.logger.Printf("Snapshot of %s failed: %v", rec.GetCameraName(), err)
+			http.Error(w, "Snapshot not available", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(len(img)))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(img)
+}
+
+// writePauseResult reports a camera's pause state after a change
+func (s *Server) writePauseResult(w http.ResponseWriter, rec *recorder.Recorder) {
+	pause, paused := rec.PauseStatus()
+
+	result := map[string]interface{}{
+		"camera": rec.GetCameraName(),
+		"paused": paused,
+	}
+	if paused {
+		result["since"] = pause.Since
+		if !pause.ResumeAt.IsZero() {
+			result["resume_at"] = pause.ResumeAt
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// cameraError maps camera management errors to HTTP responses
+func (s *Server) cameraError(w http.ResponseWriter, name string, err error) {
+	switch {
+	case errors.Is(err, supervisor.ErrCameraNotFound):
+		http.Error(w, "Camera not found", http.StatusNotFound)
+	case errors.Is(err, supervisor.ErrCameraExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, supervisor.ErrCameraRenamed), errors.Is(err, supervisor.ErrInvalidConfig):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		s.logger.Printf("Failed to change camera %s: %v", name, err)
+		http.Error(w, "Failed to save camera", http.StatusInternalServerError)
+	}
+}
+
+// writeCameraResult reports a successful camera change
+func (s *Server) writeCameraResult(w http.ResponseWriter, code int, status, name string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"camera": name,
+	})
+}
@@ -0,0 +1,137 @@
+package webui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/events"
+)
+
+// defaultEventsRange is how far back /api/events looks without a from time
+const defaultEventsRange = 24 * time.Hour
+
+// eventResult is an event with where to play it back
+type eventResult struct {
+	events.Event
+	Recording *eventRecording `json:"recording,omitempty"`
+}
+
+// eventRecording is the recording an event starts in
+type eventRecording struct {
+	URL    string  `json:"url"`
+	Offset float64 `json:"offset"` // Seconds into the recording
+}
+
+// handleEvents lists the events cameras detected
+// GET /api/events?camera=&type=&label=&from=&to= returns the events that
+// overlap the range, oldest first, each with the recording it starts in if
+// there is one. label picks events with a detected object such as person.
+// Times are RFC 3339, YYYY-MM-DD or Unix seconds; the range defaults to the
+// last 24 hours.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.events == nil {
+		http.Error(w, "Events not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	camera := query.Get("camera")
+	if strings.ContainsAny(camera, `/\`) || strings.Contains(camera, "..") {
+		http.Error(w, "invalid camera", http.StatusBadRequest)
+		return
+	}
+
+	to := time.Now()
+	if value := query.Get("to"); value != "" {
+		t, err := parseEventTime(value, true)
+		if err != nil {
+			http.Error(w, "invalid to time", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultEventsRange)
+	if value := query.Get("from"); value != "" {
+		t, err := parseEventTime(value, false)
+		if err != nil {
+			http.Error(w, "invalid from time", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if to.Before(from) {
+		http.Error(w, "to is before from", http.StatusBadRequest)
+		return
+	}
+
+	found, err := s.events.Find(events.Query{
+		Camera: camera,
+		Type:   query.Get("type"),
+		Label:  query.Get("label"),
+		From:   from,
+		To:     to,
+	})
+	if err != nil {
+		s.logger.Printf("Failed to find events: %v", err)
+		http.Error(w, "Failed to read events", http.StatusInternalServerError)
+		return
+	}
+
+	results := make([]eventResult, len(found))
+	for i, ev := range found {
+		results[i] = eventResult{Event: ev, Recording: s.eventRecording(ev)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":   from,
+		"to":     to,
+		"count":  len(results),
+		"events": results,
+	})
+}
+
+// eventRecording finds the recording an event starts in, or nil if it wasn't
+// recorded. A recording started the day before may run past midnight.
+func (s *Server) eventRecording(ev events.Event) *eventRecording {
+	for _, day := range []time.Time{ev.Start, ev.Start.AddDate(0, 0, -1)} {
+		date := day.Format("2006-01-02")
+		for _, seg := range s.catalog.Segments(ev.Camera, date) {
+			if ev.Start.Before(seg.Start) || !ev.Start.Before(seg.End) {
+				continue
+			}
+			return &eventRecording{
+				URL:    fmt.Sprintf("/recordings/%s/%s/%s", ev.Camera, date, seg.Filename),
+				Offset: ev.Start.Sub(seg.Start).Seconds(),
+			}
+		}
+	}
+	return nil
+}
+
+// parseEventTime parses an RFC 3339 time, a local date or Unix seconds. A
+// date means the start of the day, or its end if endOfDay is set.
+func parseEventTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if day, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+		return day, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0), nil
+}
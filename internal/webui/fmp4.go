@@ -0,0 +1,312 @@
+package webui
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// fmp4Fragment is one moof+mdat pair of a fragmented MP4 recording
+type fmp4Fragment struct {
+	Offset int64
+	Size   int64
+	Start  float64 // Seconds, from the video track's tfdt
+}
+
+// fmp4Index describes the layout of a fragmented MP4 recording
+type fmp4Index struct {
+	InitSize  int64 // Bytes of ftyp+moov at the start of the file
+	Fragments []fmp4Fragment
+}
+
+// mp4Box is a box header read from a file
+type mp4Box struct {
+	Type       string
+	Offset     int64
+	HeaderSize int64
+	Size       int64
+}
+
+// readFMP4Index scans a fragmented MP4 file's top-level boxes to find its
+// init section and complete fragments. A fragment still being written (or
+// cut off by a crash) is left out.
+func readFMP4Index(path string) (*fmp4Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening recording: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat recording: %w", err)
+	}
+	fileSize := info.Size()
+
+	idx := &fmp4Index{}
+	var videoTrack uint32
+	var timescale uint32
+	var pending *fmp4Fragment // moof waiting for its mdat
+
+	for offset := int64(0); offset < fileSize; {
+		box, err := readMP4BoxHeader(f, offset, fileSize)
+		if err != nil {
+			return nil, err
+		}
+		if offset+box.Size > fileSize {
+			break // Incomplete box at the end of the file
+		}
+
+		switch box.Type {
+		case "moov":
+			body, err := readMP4BoxBody(f, box)
+			if err != nil {
+				return nil, err
+			}
+			videoTrack, timescale = parseMoovVideoTrack(body)
+			idx.InitSize = offset + box.Size
+
+		case "moof":
+			body, err := readMP4BoxBody(f, box)
+			if err != nil {
+				return nil, err
+			}
+			start := 0.0
+			if timescale > 0 {
+				start = float64(parseMoofDecodeTime(body, videoTrack)) / float64(timescale)
+			}
+			pending = &fmp4Fragment{Offset: offset, Start: start}
+
+		case "mdat":
+			if pending != nil {
+				pending.Size = offset + box.Size - pending.Offset
+				idx.Fragments = append(idx.Fragments, *pending)
+				pending = nil
+			}
+		}
+
+		offset += box.Size
+	}
+
+	if idx.InitSize == 0 || timescale == 0 {
+		return nil, fmt.Errorf("not a fragmented MP4 recording")
+	}
+	if len(idx.Fragments) == 0 {
+		return nil, fmt.Errorf("no complete fragments")
+	}
+
+	return idx, nil
+}
+
+// readMP4BoxHeader reads the box header at offset
+func readMP4BoxHeader(r io.ReaderAt, offset, fileSize int64) (mp4Box, error) {
+	header := make([]byte, 16)
+	n, err := r.ReadAt(header, offset)
+	if n < 8 {
+		return mp4Box{}, fmt.Errorf("reading box at %d: %w", offset, err)
+	}
+
+	box := mp4Box{
+		Type:       string(header[4:8]),
+		Offset:     offset,
+		HeaderSize: 8,
+		Size:       int64(binary.BigEndian.Uint32(header[0:4])),
+	}
+
+	switch box.Size {
+	case 0:
+		// Box extends to the end of the file
+		box.Size = fileSize - offset
+	case 1:
+		if n < 16 {
+			return mp4Box{}, fmt.Errorf("reading box size at %d: %w", offset, err)
+		}
+		box.Size = int64(binary.BigEndian.Uint64(header[8:16]))
+		box.HeaderSize = 16
+	}
+
+	if box.Size < box.HeaderSize {
+		return mp4Box{}, fmt.Errorf("invalid %s box at %d", box.Type, offset)
+	}
+	return box, nil
+}
+
+// readMP4BoxBody reads a box's contents after its header
+func readMP4BoxBody(r io.ReaderAt, box mp4Box) ([]byte, error) {
+	body := make([]byte, box.Size-box.HeaderSize)
+	if _, err := r.ReadAt(body, box.Offset+box.HeaderSize); err != nil {
+		return nil, fmt.Errorf("reading %s box: %w", box.Type, err)
+	}
+	return body, nil
+}
+
+// eachMP4Box calls fn for every child box in data
+func eachMP4Box(data []byte, fn func(boxType string, body []byte)) {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data[0:4]))
+		headerSize := 8
+		if size == 1 && len(data) >= 16 {
+			size = int(binary.BigEndian.Uint64(data[8:16]))
+			headerSize = 16
+		} else if size == 0 {
+			size = len(data)
+		}
+		if size < headerSize || size > len(data) {
+			return
+		}
+
+		fn(string(data[4:8]), data[headerSize:size])
+		data = data[size:]
+	}
+}
+
+// parseMoovVideoTrack returns the track ID and timescale of the first video
+// track in a moov box
+func parseMoovVideoTrack(moov []byte) (trackID, timescale uint32) {
+	eachMP4Box(moov, func(boxType string, trak []byte) {
+		if boxType != "trak" || timescale != 0 {
+			return
+		}
+
+		var id, scale uint32
+		var handler string
+		eachMP4Box(trak, func(boxType string, body []byte) {
+			switch boxType {
+			case "tkhd":
+				id = fullBoxUint32(body, 12, 20)
+			case "mdia":
+				eachMP4Box(body, func(boxType string, body []byte) {
+					switch boxType {
+					case "mdhd":
+						scale = fullBoxUint32(body, 12, 20)
+					case "hdlr":
+						if len(body) >= 12 {
+							handler = string(body[8:12])
+						}
+					}
+				})
+			}
+		})
+
+		if handler == "vide" {
+			trackID, timescale = id, scale
+		}
+	})
+	return trackID, timescale
+}
+
+// parseMoofDecodeTime returns the base media decode time of a track's
+// fragment in a moof box
+func parseMoofDecodeTime(moof []byte, trackID uint32) uint64 {
+	var decodeTime uint64
+	eachMP4Box(moof, func(boxType string, traf []byte) {
+		if boxType != "traf" {
+			return
+		}
+
+		var id uint32
+		var time uint64
+		eachMP4Box(traf, func(boxType string, body []byte) {
+			switch boxType {
+			case "tfhd":
+				if len(body) >= 8 {
+					id = binary.BigEndian.Uint32(body[4:8])
+				}
+			case "tfdt":
+				if len(body) >= 12 && body[0] == 1 {
+					time = binary.BigEndian.Uint64(body[4:12])
+				} else if len(body) >= 8 {
+					time = uint64(binary.BigEndian.Uint32(body[4:8]))
+				}
+			}
+		})
+
+		if id == trackID {
+			decodeTime = time
+		}
+	})
+	return decodeTime
+}
+
+// fullBoxUint32 reads a uint32 field from a full box body, whose offset
+// depends on the box version (0 or 1)
+func fullBoxUint32(body []byte, offsetV0, offsetV1 int) uint32 {
+	if len(body) < 4 {
+		return 0
+	}
+
+	offset := offsetV0
+	if body[0] == 1 {
+		offset = offsetV1
+	}
+	if len(body) < offset+4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(body[offset : offset+4])
+}
+
+// generateFMP4Playlist creates an HLS fMP4 playlist that addresses the
+// recording's init section and fragments by byte range, grouping fragments
+// into ~10 second media segments. duration is the recording's total length.
+func (s *Server) generateFMP4Playlist(camera, date, filename string, idx *fmp4Index, duration float64) string {
+	recordingURL := fmt.Sprintf("/recordings/%s/%s/%s", camera, date, filename)
+	targetDuration := 10.0
+
+	base := idx.Fragments[0].Start
+	last := idx.Fragments[len(idx.Fragments)-1]
+	end := duration
+	if end <= last.Start-base {
+		// Catalog duration is behind the file; assume the last fragment
+		// is as long as the target
+		end = last.Start - base + targetDuration
+	}
+
+	var segments []string
+	var maxSegmentDuration float64
+
+	segmentStart := 0
+	for i := 1; i <= len(idx.Fragments); i++ {
+		startTime := idx.Fragments[segmentStart].Start - base
+		endTime := end
+		if i < len(idx.Fragments) {
+			endTime = idx.Fragments[i].Start - base
+		}
+		segmentDuration := endTime - startTime
+
+		if i < len(idx.Fragments) && segmentDuration < targetDuration {
+			continue
+		}
+
+		first := idx.Fragments[segmentStart]
+		lastFragment := idx.Fragments[i-1]
+		byteLength := lastFragment.Offset + lastFragment.Size - first.Offset
+
+		if segmentDuration > 0 && byteLength > 0 {
+			segments = append(segments,
+				fmt.Sprintf("#EXTINF:%.3f,\n#EXT-X-BYTERANGE:%d@%d\n%s",
+					segmentDuration, byteLength, first.Offset, recordingURL))
+			if segmentDuration > maxSegmentDuration {
+				maxSegmentDuration = segmentDuration
+			}
+		}
+		segmentStart = i
+	}
+
+	targetDurationInt := int(maxSegmentDuration) + 1
+	if targetDurationInt < 10 {
+		targetDurationInt = 10
+	}
+
+	return fmt.Sprintf(`#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:%d
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="%s",BYTERANGE="%d@0"
+%s
+#EXT-X-ENDLIST
+`, targetDurationInt, recordingURL, idx.InitSize, strings.Join(segments, "\n"))
+}
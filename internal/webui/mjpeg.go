@@ -0,0 +1,78 @@
+package webui
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/recorder"
+)
+
+// mjpegBoundary separates the images of an MJPEG stream
+const mjpegBoundary = "corenvr-frame"
+
+// mjpegKeepAliveInterval is how often an MJPEG client tells the recorder
+// it's still watching, so on-demand live streams keep running
+const mjpegKeepAliveInterval = 5 * time.Second
+
+// handleMJPEG streams a camera's live view as Motion JPEG
+// GET /mjpeg/{camera} returns a multipart/x-mixed-replace stream of JPEGs
+func (s *Server) handleMJPEG(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cameraName := strings.Trim(strings.TrimPrefix(r.URL.Path, "/mjpeg/"), "/")
+	rec, ok := s.cameras.Recorder(cameraName)
+	if !ok {
+		http.Error(w, "Camera not found", http.StatusNotFound)
+		return
+	}
+	segmenter, _ := rec.Segmenter(recorder.PipelineLive)
+
+	// The client keeps an on-demand live stream running like an HLS player
+	client := streamClient(r)
+	rec.RequestStream(recorder.PipelineLive, client)
+
+	images, unsubscribe := s.mjpeg.Subscribe(cameraName, segmenter)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	flusher, _ := w.(http.Flusher)
+
+	ticker := time.NewTicker(mjpegKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-ticker.C:
+			rec.RequestStream(recorder.PipelineLive, client)
+
+		case img, ok := <-images:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n",
+				mjpegBoundary, len(img)); err != nil {
+				return
+			}
+			if _, err := w.Write(img); err != nil {
+				return
+			}
+			if _, err := w.Write([]byte("\r\n")); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -16,7 +18,15 @@ import (
 	"time"
 
 	"github.com/mmuteeullah/CoreNVR/internal/auth"
+	"github.com/mmuteeullah/CoreNVR/internal/catalog"
 	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/events"
+	"github.com/mmuteeullah/CoreNVR/internal/mjpeg"
+	"github.com/mmuteeullah/CoreNVR/internal/recorder"
+	"github.com/mmuteeullah/CoreNVR/internal/rtc"
+	"github.com/mmuteeullah/CoreNVR/internal/rtsp"
+	"github.com/mmuteeullah/CoreNVR/internal/supervisor"
+	"github.com/mmuteeullah/CoreNVR/internal/tamper"
 )
 
 // KeyframeInfo stores byte offset and timestamp for a keyframe
@@ -42,14 +52,20 @@ var (
 // Server represents the web UI server
 type Server struct {
 	config         *config.Config
+	catalog        *catalog.Catalog
+	cameras        *supervisor.Supervisor
 	port           int
 	logger         *log.Logger
 	sessionManager *auth.SessionManager
 	authEnabled    bool
+	rtc            *rtc.Server  // nil unless WebRTC is enabled
+	rtsp           *rtsp.Server // nil unless RTSP re-streaming is enabled
+	mjpeg          *mjpeg.Server
+	events         *events.Store // nil until SetEventStore
 }
 
 // NewServer creates a new web UI server
-func NewServer(cfg *config.Config, port int) *Server {
+func NewServer(cfg *config.Config, port int, cat *catalog.Catalog, cameras *supervisor.Supervisor) *Server {
 	var sessionManager *auth.SessionManager
 	authEnabled := cfg.WebUI.Authentication.Enabled
 
@@ -62,15 +78,40 @@ func NewServer(cfg *config.Config, port int) *Server {
 		)
 	}
 
+	logger := log.New(os.Stdout, "[WebUI] ", log.LstdFlags)
+
+	var rtcServer *rtc.Server
+	if cfg.WebUI.WebRTC.Enabled {
+		var err error
+		if rtcServer, err = rtc.NewServer(cfg.WebUI.WebRTC); err != nil {
+			logger.Printf("⚠️  WebRTC disabled: %v", err)
+		}
+	}
+
 	return &Server{
 		config:         cfg,
+		catalog:        cat,
+		cameras:        cameras,
 		port:           port,
-		logger:         log.New(os.Stdout, "[WebUI] ", log.LstdFlags),
+		logger:         logger,
 		sessionManager: sessionManager,
 		authEnabled:    authEnabled,
+		rtc:            rtcServer,
+		mjpeg:          mjpeg.NewServer(cfg.WebUI.MJPEG),
 	}
 }
 
+// SetRTSPServer lists the RTSP re-streaming server's URLs and statistics
+// in the camera API
+func (s *Server) SetRTSPServer(server *rtsp.Server) {
+	s.rtsp = server
+}
+
+// SetEventStore serves the events cameras detect, such as motion
+func (s *Server) SetEventStore(store *events.Store) {
+	s.events = store
+}
+
 // Start begins the web server
 func (s *Server) Start() {
 	if s.authEnabled {
@@ -82,9 +123,13 @@ func (s *Server) Start() {
 		// Protected routes (require authentication)
 		http.HandleFunc("/api/status", s.requireAuth(s.handleAPIStatus))
 		http.HandleFunc("/api/cameras", s.requireAuth(s.handleAPICameras))
+		http.HandleFunc("/api/cameras/", s.requireAuth(s.handleCameraAPI))
 		http.HandleFunc("/api/storage", s.requireAuth(s.handleAPIStorage))
 		http.HandleFunc("/api/recordings/", s.requireAuth(s.handleRecordingsAPI))
+		http.HandleFunc("/api/events", s.requireAuth(s.handleEvents))
 		http.HandleFunc("/stream/", s.requireAuth(s.handleStream))
+		http.HandleFunc("/webrtc/", s.requireAuth(s.handleWebRTC))
+		http.HandleFunc("/mjpeg/", s.requireAuth(s.handleMJPEG))
 		http.HandleFunc("/segments/", s.requireAuth(s.handleSegments))
 		http.HandleFunc("/recordings/", s.requireAuth(s.handleRecordingPlayback))
 		http.HandleFunc("/", s.requireAuth(s.handleIndex))
@@ -94,10 +139,14 @@ func (s *Server) Start() {
 		// No authentication - all routes public
 		http.HandleFunc("/api/status", s.handleAPIStatus)
 		http.HandleFunc("/api/cameras", s.handleAPICameras)
+		http.HandleFunc("/api/cameras/", s.handleCameraAPI)
 		http.HandleFunc("/api/storage", s.handleAPIStorage)
 		http.HandleFunc("/api/recordings/", s.handleRecordingsAPI)
+		http.HandleFunc("/api/events", s.handleEvents)
 		http.HandleFunc("/health", s.handleHealth)
 		http.HandleFunc("/stream/", s.handleStream)
+		http.HandleFunc("/webrtc/", s.handleWebRTC)
+		http.HandleFunc("/mjpeg/", s.handleMJPEG)
 		http.HandleFunc("/segments/", s.handleSegments)
 		http.HandleFunc("/recordings/", s.handleRecordingPlayback)
 		http.HandleFunc("/", s.handleIndex)
@@ -149,33 +198,78 @@ func (s *Server) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(status)
 }
 
-// handleAPICameras returns camera status as JSON
+// handleAPICameras returns camera status as JSON, or adds a camera on POST
 func (s *Server) handleAPICameras(w http.ResponseWriter, r *http.Request) {
-	cameras := []map[string]interface{}{}
+	if r.Method == http.MethodPost {
+		s.handleAddCamera(w, r)
+		return
+	}
 
-	for _, cam := range s.config.Cameras {
-		// Check if camera is recording by looking at today's recording folder
-		recordingsPath := filepath.Join(s.config.Storage.BasePath, cam.Name, "recordings")
-		todayPath := filepath.Join(recordingsPath, time.Now().Format("2006-01-02"))
+	cameras := []map[string]interface{}{}
 
-		isRecording := false
+	for _, cam := range s.cameras.Cameras() {
 		lastFile := ""
 		var lastModTime time.Time
 
-		// Check if today's folder exists and has recent files
-		if _, err := os.Stat(todayPath); err == nil {
-			// Get all .ts files in today's folder
-			files, _ := filepath.Glob(filepath.Join(todayPath, "*.ts"))
-			if len(files) > 0 {
-				lastFile = filepath.Base(files[len(files)-1])
-
-				// Check if the last file was modified recently (within 5 minutes)
-				if info, err := os.Stat(files[len(files)-1]); err == nil {
-					lastModTime = info.ModTime()
-					if time.Since(lastModTime) < 5*time.Minute {
-						isRecording = true
-					}
-				}
+		// The latest cataloged segment includes the one being written
+		if seg, ok := s.catalog.Latest(cam.Name); ok {
+			lastFile = seg.Filename
+			lastModTime = seg.End
+		}
+
+		// Recording state comes from the recorder itself
+		isRecording := false
+		recordState := string(recorder.StateStopped)
+		liveState := string(recorder.StateStopped)
+		failureReason := ""
+		fps, bitrate := 0.0, 0.0
+		var pause *recorder.PauseStatus
+		var tamperState *tamper.State
+		viewers := 0
+		if rec, ok := s.cameras.Recorder(cam.Name); ok {
+			status := rec.Status()
+			pause = status.Paused
+			tamperState = status.Tamper
+			viewers = status.Live.Viewers
+			if status.Preview != nil {
+				viewers += status.Preview.Viewers
+			}
+			isRecording = status.Record.State == recorder.StateRecording
+			recordState = string(status.Record.State)
+			liveState = string(status.Live.State)
+			failureReason = status.Record.FailureReason.Description()
+			if status.Record.Progress != nil {
+				fps = status.Record.Progress.FPS
+				bitrate = status.Record.Progress.BitrateKbps
+			}
+		}
+
+		// Grid tiles use the low-res preview stream when one is configured
+		livePlaylist := fmt.Sprintf("/stream/%s/playlist.m3u8", cam.Name)
+		gridPlaylist := livePlaylist
+		if cam.PreviewURL != "" {
+			gridPlaylist = fmt.Sprintf("/stream/%s/preview.m3u8", cam.Name)
+		}
+
+		// The single camera view tries WebRTC first when it's enabled
+		webrtcURL := ""
+		if s.rtc != nil {
+			webrtcURL = "/webrtc/" + cam.Name
+		}
+
+		// RTSP clients connect to the same host the browser used
+		var restream map[string]interface{}
+		if s.rtsp != nil {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = h
+			}
+			stats := s.rtsp.Stats(cam.Name)
+			restream = map[string]interface{}{
+				"url":          fmt.Sprintf("rtsp://%s/%s", net.JoinHostPort(host, strconv.Itoa(s.rtsp.Port())), cam.Name),
+				"clients":      stats.Clients,
+				"bytes_sent":   stats.BytesSent,
+				"bitrate_kbps": stats.BitrateKbps,
 			}
 		}
 
@@ -183,8 +277,23 @@ func (s *Server) handleAPICameras(w http.ResponseWriter, r *http.Request) {
 			"name":          cam.Name,
 			"enabled":       cam.Enabled,
 			"recording":     isRecording,
+			"record_state":  recordState,
+			"live_state":    liveState,
+			"failure":       failureReason,
+			"paused":        pause,
+			"tamper":        tamperState,
+			"viewers":       viewers,
+			"fps":           fps,
+			"bitrate_kbps":  bitrate,
 			"last_file":     lastFile,
 			"last_modified": lastModTime.Format("15:04:05"),
+			"streams":       cam.StreamSources(),
+			"live_playlist": livePlaylist,
+			"grid_playlist": gridPlaylist,
+			"webrtc":        webrtcURL,
+			"rtsp":          restream,
+			"mjpeg":         "/mjpeg/" + cam.Name,
+			"snapshot":      "/api/cameras/" + cam.Name + "/snapshot.jpg",
 		})
 	}
 
@@ -192,6 +301,56 @@ func (s *Server) handleAPICameras(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(cameras)
 }
 
+// handleCameraAPI routes /api/cameras/{name} and /api/cameras/{name}/*
+// requests to appropriate handlers
+func (s *Server) handleCameraAPI(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/cameras/"), "/"), "/")
+	if parts[0] == "" || len(parts) > 2 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodPut:
+			s.handleUpdateCamera(w, r, parts[0])
+		case http.MethodDelete:
+			s.handleRemoveCamera(w, r, parts[0])
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	cameraName, action := parts[0], parts[1]
+	rec, ok := s.cameras.Recorder(cameraName)
+	if !ok {
+		http.Error(w, "Camera not found", http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "status":
+		s.handleCameraStatus(w, r, rec)
+	case "pause":
+		s.handlePauseCamera(w, r, rec)
+	case "resume":
+		s.handleResumeCamera(w, r, rec)
+	case "snapshot.jpg":
+		s.handleSnapshot(w, r, rec)
+	case "trigger":
+		s.handleTrigger(w, r, rec)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handleCameraStatus returns the recorder state of a single camera
+func (s *Server) handleCameraStatus(w http.ResponseWriter, r *http.Request, rec *recorder.Recorder) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec.Status())
+}
+
 // handleHealth simple health check endpoint
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -225,23 +384,13 @@ func (s *Server) handleAPIStorage(w http.ResponseWriter, r *http.Request) {
 
 	// Get per-camera storage info
 	cameras := []map[string]interface{}{}
-	for _, cam := range s.config.Cameras {
+	for _, cam := range s.cameras.Cameras() {
 		if !cam.Enabled {
 			continue
 		}
 
-		cameraBasePath := filepath.Join(s.config.Storage.BasePath, cam.Name, "recordings")
-		cameraSize := s.getDirSize(cameraBasePath)
-
-		// Count recording days
-		days := 0
-		if entries, err := os.ReadDir(cameraBasePath); err == nil {
-			for _, entry := range entries {
-				if entry.IsDir() && len(entry.Name()) == 10 {
-					days++
-				}
-			}
-		}
+		cameraSize := s.catalog.Size(cam.Name, "")
+		days := len(s.catalog.Dates(cam.Name))
 
 		cameras = append(cameras, map[string]interface{}{
 			"name":         cam.Name,
@@ -262,27 +411,13 @@ func (s *Server) handleAPIStorage(w http.ResponseWriter, r *http.Request) {
 		"alert_level":      alertLevel,
 		"retention_days":   s.config.Storage.RetentionDays,
 		"cameras":          cameras,
+		"segment_repairs":  s.catalog.RepairStats(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// getDirSize calculates total size of a directory
-func (s *Server) getDirSize(path string) int64 {
-	var size int64
-	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			size += info.Size()
-		}
-		return nil
-	})
-	return size
-}
-
 // handleRecordingsAPI routes /api/recordings/* requests to appropriate handlers
 func (s *Server) handleRecordingsAPI(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
@@ -314,21 +449,9 @@ func (s *Server) handleRecordingDates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	recordingsPath := filepath.Join(s.config.Storage.BasePath, camera, "recordings")
-	entries, err := os.ReadDir(recordingsPath)
-	if err != nil {
-		http.Error(w, "Failed to read recordings", http.StatusInternalServerError)
-		return
-	}
-
-	var dates []string
-	for _, entry := range entries {
-		if entry.IsDir() && len(entry.Name()) == 10 {
-			// Verify it's a valid date format YYYY-MM-DD
-			if _, err := time.Parse("2006-01-02", entry.Name()); err == nil {
-				dates = append(dates, entry.Name())
-			}
-		}
+	dates := s.catalog.Dates(camera)
+	if dates == nil {
+		dates = []string{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -354,13 +477,6 @@ func (s *Server) handleRecordingsList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	datePath := filepath.Join(s.config.Storage.BasePath, camera, "recordings", date)
-	files, err := filepath.Glob(filepath.Join(datePath, "*.ts"))
-	if err != nil {
-		http.Error(w, "Failed to list recordings", http.StatusInternalServerError)
-		return
-	}
-
 	type Recording struct {
 		Filename    string `json:"filename"`
 		StartTime   string `json:"start_time"`
@@ -372,25 +488,15 @@ func (s *Server) handleRecordingsList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	recordings := []Recording{}
-	for _, file := range files {
-		info, err := os.Stat(file)
-		if err != nil {
-			continue
-		}
-
-		filename := filepath.Base(file)
-		// Parse time from filename (HH-MM-SS.ts)
-		timeStr := filename[:8] // HH-MM-SS
-		startTime := date + " " + timeStr[:2] + ":" + timeStr[3:5] + ":" + timeStr[6:8]
-
+	for _, seg := range s.catalog.Segments(camera, date) {
 		recordings = append(recordings, Recording{
-			Filename:    filename,
-			StartTime:   startTime,
-			Size:        info.Size(),
-			SizeMB:      fmt.Sprintf("%.2f", float64(info.Size())/(1024*1024)),
-			Duration:    1800, // 30 minutes in seconds
-			URL:         fmt.Sprintf("/recordings/%s/%s/%s", camera, date, filename),
-			PlaylistURL: fmt.Sprintf("/api/recordings/playlist/%s/%s/%s", camera, date, filename),
+			Filename:    seg.Filename,
+			StartTime:   seg.Start.Format("2006-01-02 15:04:05"),
+			Size:        seg.Size,
+			SizeMB:      fmt.Sprintf("%.2f", float64(seg.Size)/(1024*1024)),
+			Duration:    int(seg.Duration().Seconds()),
+			URL:         fmt.Sprintf("/recordings/%s/%s/%s", camera, date, seg.Filename),
+			PlaylistURL: fmt.Sprintf("/api/recordings/playlist/%s/%s/%s", camera, date, seg.Filename),
 		})
 	}
 
@@ -420,13 +526,6 @@ func (s *Server) handleRecordingsTimeline(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	datePath := filepath.Join(s.config.Storage.BasePath, camera, "recordings", date)
-	files, err := filepath.Glob(filepath.Join(datePath, "*.ts"))
-	if err != nil {
-		http.Error(w, "Failed to list recordings", http.StatusInternalServerError)
-		return
-	}
-
 	type Segment struct {
 		StartTime string `json:"start_time"`
 		EndTime   string `json:"end_time"`
@@ -444,18 +543,9 @@ func (s *Server) handleRecordingsTimeline(w http.ResponseWriter, r *http.Request
 	gaps := []Gap{}
 
 	// Parse all segments
-	for _, file := range files {
-		info, err := os.Stat(file)
-		if err != nil {
-			continue
-		}
-
-		filename := filepath.Base(file)
-		timeStr := filename[:8]
-
-		startTime, _ := time.Parse("2006-01-02 15:04:05",
-			fmt.Sprintf("%s %s:%s:%s", date, timeStr[:2], timeStr[3:5], timeStr[6:8]))
-		endTime := startTime.Add(30 * time.Minute)
+	for _, seg := range s.catalog.Segments(camera, date) {
+		startTime := seg.Start
+		endTime := seg.End
 
 		// Cap end time to 23:59:59 if it wraps to next day
 		endTimeStr := endTime.Format("15:04:05")
@@ -466,8 +556,8 @@ func (s *Server) handleRecordingsTimeline(w http.ResponseWriter, r *http.Request
 		segments = append(segments, Segment{
 			StartTime: startTime.Format("15:04:05"),
 			EndTime:   endTimeStr,
-			Filename:  filename,
-			SizeMB:    fmt.Sprintf("%.2f", float64(info.Size())/(1024*1024)),
+			Filename:  seg.Filename,
+			SizeMB:    fmt.Sprintf("%.2f", float64(seg.Size)/(1024*1024)),
 		})
 	}
 
@@ -541,8 +631,50 @@ func (s *Server) handleRecordingsTimeline(w http.ResponseWriter, r *http.Request
 		})
 	}
 
-	// Calculate coverage based on actual recorded time (not just segment count)
-	totalMinutes := 24 * 60
+	// Time recording was scheduled off or paused isn't a gap
+	toGaps := func(periods []timelinePeriod) []Gap {
+		result := []Gap{}
+		for _, p := range periods {
+			result = append(result, Gap{
+				StartTime:    p.Start.Format("15:04:05"),
+				EndTime:      p.End.Format("15:04:05"),
+				DurationMins: int(p.End.Sub(p.Start).Minutes()),
+			})
+		}
+		return result
+	}
+
+	var offPeriods []timelinePeriod
+	if cam, ok := s.cameras.Camera(camera); ok {
+		offPeriods = scheduledOffPeriods(cam, dateObj)
+	}
+	paused := pausedPeriods(s.config.Storage.BasePath, camera, dateObj)
+
+	notExpected := mergePeriods(append(append([]timelinePeriod(nil), offPeriods...), paused...))
+	notExpectedMinutes := 0
+	for _, p := range notExpected {
+		notExpectedMinutes += int(p.End.Sub(p.Start).Minutes())
+	}
+
+	if len(notExpected) > 0 {
+		unexpected := gaps
+		gaps = []Gap{}
+		for _, gap := range unexpected {
+			start, _ := time.Parse("15:04:05", gap.StartTime)
+			end, _ := time.Parse("15:04:05", gap.EndTime)
+			period := timelinePeriod{Start: timelineClock(dateObj, start), End: timelineClock(dateObj, end)}
+
+			for _, rest := range subtractPeriods(period, notExpected) {
+				if rest.End.Sub(rest.Start) > 2*time.Minute {
+					gaps = append(gaps, toGaps([]timelinePeriod{rest})...)
+				}
+			}
+		}
+	}
+
+	// Calculate coverage based on actual recorded time (not just segment count),
+	// out of the time recording was scheduled and not paused
+	totalMinutes := 24*60 - notExpectedMinutes
 	recordedMinutes := 0
 
 	if len(segments) > 0 {
@@ -556,7 +688,10 @@ func (s *Server) handleRecordingsTimeline(w http.ResponseWriter, r *http.Request
 		recordedMinutes = totalMinutes - totalGapMinutes
 	}
 
-	coveragePercent := float64(recordedMinutes) / float64(totalMinutes) * 100
+	coveragePercent := 0.0
+	if totalMinutes > 0 {
+		coveragePercent = float64(recordedMinutes) / float64(totalMinutes) * 100
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -566,6 +701,8 @@ func (s *Server) handleRecordingsTimeline(w http.ResponseWriter, r *http.Request
 		"gaps":             gaps,
 		"total_segments":   len(segments),
 		"total_gaps":       len(gaps),
+		"scheduled_off":    toGaps(offPeriods),
+		"paused":           toGaps(paused),
 		"coverage_percent": fmt.Sprintf("%.1f", coveragePercent),
 		"recorded_hours":   fmt.Sprintf("%.1f", float64(recordedMinutes)/60),
 	})
@@ -614,8 +751,8 @@ func (s *Server) handleRecordingPlayback(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Validate filename (should end with .ts)
-	if filepath.Ext(filename) != ".ts" {
+	// Validate filename (should end with .ts or .mp4)
+	if !catalog.IsSegmentFile(filename) {
 		http.Error(w, "Invalid file type", http.StatusBadRequest)
 		return
 	}
@@ -632,7 +769,11 @@ func (s *Server) handleRecordingPlayback(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Set appropriate content type and CORS headers for Safari
-	w.Header().Set("Content-Type", "video/mp2t")
+	if filepath.Ext(filename) == ".mp4" {
+		w.Header().Set("Content-Type", "video/mp4")
+	} else {
+		w.Header().Set("Content-Type", "video/mp2t")
+	}
 	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
@@ -790,6 +931,17 @@ func (s *Server) generateByteRangePlaylist(camera, date, filename string, kf *Fi
 `, targetDurationInt, strings.Join(segments, "\n"))
 }
 
+// recordingDuration returns a recording's duration in seconds from the
+// catalog, falling back to the configured segment duration
+func (s *Server) recordingDuration(camera, date, filename string) float64 {
+	for _, seg := range s.catalog.Segments(camera, date) {
+		if seg.Filename == filename && seg.Duration() > 0 {
+			return seg.Duration().Seconds()
+		}
+	}
+	return float64(s.config.Storage.SegmentDuration)
+}
+
 // handleRecordingPlaylist generates an HLS playlist for a single recording file
 // URL format: /api/recordings/playlist/{camera}/{date}/{filename}
 func (s *Server) handleRecordingPlaylist(w http.ResponseWriter, r *http.Request) {
@@ -847,19 +999,29 @@ func (s *Server) handleRecordingPlaylist(w http.ResponseWriter, r *http.Request)
 	var playlist string
 	recordingURL := fmt.Sprintf("/recordings/%s/%s/%s", camera, date, filename)
 
-	keyframes, err := s.getKeyframes(filePath)
-	if err != nil {
+	if filepath.Ext(filename) == ".mp4" {
+		// Fragmented MP4 is served as HLS fMP4: init section plus fragments
+		index, err := readFMP4Index(filePath)
+		if err != nil {
+			s.logger.Printf("Failed to index fMP4 recording %s: %v", filePath, err)
+			http.Error(w, "Recording is not playable", http.StatusInternalServerError)
+			return
+		}
+		playlist = s.generateFMP4Playlist(camera, date, filename, index, s.recordingDuration(camera, date, filename))
+		s.logger.Printf("Generated fMP4 playlist with %d fragments", len(index.Fragments))
+	} else if keyframes, err := s.getKeyframes(filePath); err != nil {
 		s.logger.Printf("Keyframe probe failed, using simple playlist: %v", err)
-		// Fallback to simple playlist if probing fails
+		// Fallback to a single-entry playlist with the cataloged duration
+		duration := s.recordingDuration(camera, date, filename)
 		playlist = fmt.Sprintf(`#EXTM3U
 #EXT-X-VERSION:3
-#EXT-X-TARGETDURATION:1800
+#EXT-X-TARGETDURATION:%d
 #EXT-X-MEDIA-SEQUENCE:0
 #EXT-X-PLAYLIST-TYPE:VOD
-#EXTINF:1800.0,
+#EXTINF:%.3f,
 %s
 #EXT-X-ENDLIST
-`, recordingURL)
+`, int(math.Ceil(duration)), duration, recordingURL)
 	} else {
 		// Use byte-range playlist with ~10 second segments
 		playlist = s.generateByteRangePlaylist(camera, date, filename, keyframes)
@@ -203,6 +203,22 @@ const htmlTemplate = `<!DOCTYPE html>
             background: var(--bg-secondary);
             border-radius: 12px;
         }
+
+        .pause-btn {
+            margin-left: 8px;
+            padding: 4px 10px;
+            font-size: 0.8em;
+            color: var(--text-secondary);
+            background: var(--bg-secondary);
+            border: 1px solid var(--border-color);
+            border-radius: 12px;
+            cursor: pointer;
+        }
+
+        .pause-btn:hover {
+            color: var(--text-primary);
+            border-color: #f59e0b;
+        }
         .video-wrapper {
             position: relative;
             padding-bottom: 56.25%; /* 16:9 */
@@ -368,6 +384,16 @@ const htmlTemplate = `<!DOCTYPE html>
             box-shadow: var(--shadow-md);
         }
 
+        .storage-thumb {
+            display: block;
+            width: 100%;
+            aspect-ratio: 16 / 9;
+            object-fit: cover;
+            border-radius: var(--radius-md);
+            margin-bottom: 12px;
+            background: #000;
+        }
+
         .storage-camera-name {
             font-weight: 600;
             color: var(--accent-green);
@@ -524,6 +550,36 @@ const htmlTemplate = `<!DOCTYPE html>
             box-shadow: 0 4px 12px rgba(239, 68, 68, 0.5);
         }
 
+        .timeline-scheduled-off {
+            position: absolute;
+            height: 100%;
+            background: repeating-linear-gradient(
+                45deg,
+                rgba(148, 163, 184, 0.15),
+                rgba(148, 163, 184, 0.15) 10px,
+                rgba(148, 163, 184, 0.3) 10px,
+                rgba(148, 163, 184, 0.3) 20px
+            );
+            border: 1px solid var(--border-color);
+            border-radius: 2px;
+            cursor: help;
+        }
+
+        .timeline-paused {
+            position: absolute;
+            height: 100%;
+            background: repeating-linear-gradient(
+                45deg,
+                rgba(245, 158, 11, 0.2),
+                rgba(245, 158, 11, 0.2) 10px,
+                rgba(245, 158, 11, 0.4) 10px,
+                rgba(245, 158, 11, 0.4) 20px
+            );
+            border: 1px solid #f59e0b;
+            border-radius: 2px;
+            cursor: help;
+        }
+
         .timeline-future {
             position: absolute;
             height: 100%;
@@ -622,6 +678,28 @@ const htmlTemplate = `<!DOCTYPE html>
             border: 1px solid var(--accent-red);
         }
 
+        .timeline-legend-color.scheduled-off {
+            background: repeating-linear-gradient(
+                45deg,
+                rgba(148, 163, 184, 0.2),
+                rgba(148, 163, 184, 0.2) 4px,
+                rgba(148, 163, 184, 0.4) 4px,
+                rgba(148, 163, 184, 0.4) 8px
+            );
+            border: 1px solid var(--border-color);
+        }
+
+        .timeline-legend-color.paused {
+            background: repeating-linear-gradient(
+                45deg,
+                rgba(245, 158, 11, 0.3),
+                rgba(245, 158, 11, 0.3) 4px,
+                rgba(245, 158, 11, 0.6) 4px,
+                rgba(245, 158, 11, 0.6) 8px
+            );
+            border: 1px solid #f59e0b;
+        }
+
         .timeline-legend-color.no-data {
             background: var(--bg-tertiary);
             border: 1px solid var(--border-color);
@@ -756,6 +834,14 @@ const htmlTemplate = `<!DOCTYPE html>
                         <div class="timeline-legend-color gap"></div>
                         <span>Missing (Past)</span>
                     </div>
+                    <div class="timeline-legend-item">
+                        <div class="timeline-legend-color scheduled-off"></div>
+                        <span>Scheduled Off</span>
+                    </div>
+                    <div class="timeline-legend-item">
+                        <div class="timeline-legend-color paused"></div>
+                        <span>Paused</span>
+                    </div>
                     <div class="timeline-legend-item">
                         <div class="timeline-legend-color future"></div>
                         <span>Future</span>
@@ -875,6 +961,9 @@ const htmlTemplate = `<!DOCTYPE html>
                 if (p.hls) {
                     p.hls.destroy();
                 }
+                if (p.pc) {
+                    p.pc.close();
+                }
             });
             players = [];
 
@@ -885,12 +974,16 @@ const htmlTemplate = `<!DOCTYPE html>
                 '<div class="camera-container">' +
                     '<div class="camera-header">' +
                         '<span class="camera-name">' + cam.name + '</span>' +
-                        '<span class="camera-status" id="status-' + index + '">' +
-                            (cam.recording ? '🔴 Recording' : '⚫ Not Recording') +
+                        '<span style="display: flex; align-items: center;">' +
+                            '<span class="camera-status" id="status-' + index + '">' + cameraStatusText(cam) + '</span>' +
+                            '<button class="pause-btn" id="pause-' + index + '" onclick="togglePause(' + index + ')">' +
+                                (cam.paused ? '▶️ Resume' : '⏸️ Pause') +
+                            '</button>' +
                         '</span>' +
                     '</div>' +
                     '<div class="video-wrapper">' +
-                        '<video id="video-' + index + '" controls muted autoplay></video>' +
+                        '<video id="video-' + index + '" controls muted autoplay' +
+                            (cam.snapshot ? ' poster="' + thumbnailURL(cam) + '"' : '') + '></video>' +
                         '<div class="video-overlay" id="overlay-' + index + '">' +
                             '<div class="spinner"></div>' +
                             '<div>Loading stream...</div>' +
@@ -905,10 +998,153 @@ const htmlTemplate = `<!DOCTYPE html>
             });
         }
 
+        // Thumbnails show the latest periodic snapshot, when the camera takes them
+        function thumbnailURL(cam) {
+            return cam.snapshot + '?cached=1&t=' + Date.now();
+        }
+
+        function cameraStatusText(cam) {
+            let text = '⚫ Not Recording';
+            if (cam.recording) {
+                text = '🔴 Recording';
+            } else if (cam.paused) {
+                text = '⏸️ Paused';
+                if (cam.paused.resume_at) {
+                    text += ' until ' + new Date(cam.paused.resume_at).toLocaleTimeString();
+                }
+            } else if (cam.record_state === 'scheduled-off') {
+                text = '⏰ Scheduled Off';
+            }
+            if (cam.tamper && cam.tamper.tampered) {
+                text += ' · 🚫 ' + cam.tamper.reason.replace('_', ' ');
+            }
+            return text + (cam.fps ? ' · ' + cam.fps.toFixed(1) + ' fps' : '') +
+                (cam.viewers ? ' · 👁 ' + cam.viewers : '');
+        }
+
+        // Pause or resume a camera's recording from its header button
+        async function togglePause(index) {
+            const enabledCameras = cameras.filter(c => c.enabled);
+            const cam = enabledCameras[index];
+            if (!cam) {
+                return;
+            }
+
+            let url = '/api/cameras/' + encodeURIComponent(cam.name) + '/resume';
+            let body = '';
+            if (!cam.paused) {
+                const minutes = prompt('Pause recording of ' + cam.name + ' for how many minutes?\n(Leave empty to pause until resumed)', '');
+                if (minutes === null) {
+                    return;
+                }
+                url = '/api/cameras/' + encodeURIComponent(cam.name) + '/pause';
+                body = JSON.stringify({ duration: Math.max(0, Math.round(parseFloat(minutes || '0') * 60)) || 0 });
+            }
+
+            try {
+                const response = await fetch(url, { method: 'POST', body: body });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+
+                // Refresh camera state without restarting the video players
+                cameras = await (await fetch('/api/cameras')).json();
+                const updated = cameras.filter(c => c.enabled)[index];
+                if (updated) {
+                    document.getElementById('status-' + index).textContent = cameraStatusText(updated);
+                    document.getElementById('pause-' + index).textContent = updated.paused ? '▶️ Resume' : '⏸️ Pause';
+                }
+            } catch (err) {
+                console.error('Failed to change pause state:', err);
+                alert('Failed to ' + (cam.paused ? 'resume' : 'pause') + ' ' + cam.name);
+            }
+        }
+
         function setupVideoPlayer(camera, index) {
+            // The single camera view uses WebRTC for the lowest delay, if
+            // the server offers it, and falls back to HLS if it fails
+            if (currentLayout === 1 && camera.webrtc && window.RTCPeerConnection) {
+                setupWebRTCPlayer(camera, index);
+            } else {
+                setupHLSPlayer(camera, index);
+            }
+        }
+
+        function setupWebRTCPlayer(camera, index) {
+            const video = document.getElementById('video-' + index);
+            const overlay = document.getElementById('overlay-' + index);
+            // No ICE servers: the NVR is on the local network
+            const pc = new RTCPeerConnection();
+            const player = { video, pc, camera };
+            players.push(player);
+
+            let fellBack = false;
+            function fallBack(reason) {
+                if (fellBack || !players.includes(player)) {
+                    return;
+                }
+                fellBack = true;
+                console.log('WebRTC unavailable for ' + camera.name + ', using HLS:', reason);
+                pc.close();
+                players.splice(players.indexOf(player), 1);
+                video.srcObject = null;
+                setupHLSPlayer(camera, index);
+            }
+
+            pc.addTransceiver('video', { direction: 'recvonly' });
+            pc.ontrack = function(event) {
+                video.srcObject = event.streams[0];
+            };
+            video.addEventListener('playing', function() {
+                overlay.style.display = 'none';
+            }, { once: true });
+            pc.onconnectionstatechange = function() {
+                if (pc.connectionState === 'failed') {
+                    fallBack('connection failed');
+                }
+            };
+
+            // Fall back if no video arrives in time
+            const timeout = setTimeout(() => fallBack('timed out'), 20000);
+            video.addEventListener('playing', () => clearTimeout(timeout), { once: true });
+
+            (async function() {
+                await pc.setLocalDescription(await pc.createOffer());
+
+                // Send the offer with all local candidates in one request
+                await new Promise(resolve => {
+                    if (pc.iceGatheringState === 'complete') {
+                        return resolve();
+                    }
+                    pc.addEventListener('icegatheringstatechange', function() {
+                        if (pc.iceGatheringState === 'complete') {
+                            resolve();
+                        }
+                    });
+                    setTimeout(resolve, 2000);
+                });
+
+                const response = await fetch(camera.webrtc, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/sdp' },
+                    body: pc.localDescription.sdp
+                });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                await pc.setRemoteDescription({ type: 'answer', sdp: await response.text() });
+
+                video.play().catch(e => {
+                    console.log('Auto-play prevented:', e);
+                });
+            })().catch(err => fallBack(err.message));
+        }
+
+        function setupHLSPlayer(camera, index) {
             const video = document.getElementById('video-' + index);
             const overlay = document.getElementById('overlay-' + index);
-            const streamUrl = '/stream/' + camera.name + '/playlist.m3u8';
+            // Grid layouts use the low-res preview stream if the camera has one
+            const streamUrl = currentLayout === 1 ? camera.live_playlist : camera.grid_playlist;
 
             if (Hls.isSupported()) {
                 const hls = new Hls({
@@ -927,8 +1163,16 @@ const htmlTemplate = `<!DOCTYPE html>
                     });
                 });
 
+                // On-demand streams take a few seconds to start, so retry
+                // before giving up
+                let retries = 0;
                 hls.on(Hls.Events.ERROR, function(event, data) {
                     console.error('HLS error:', data);
+                    if (data.fatal && data.type === Hls.ErrorTypes.NETWORK_ERROR && retries < 5) {
+                        retries++;
+                        setTimeout(() => hls.loadSource(streamUrl), 3000);
+                        return;
+                    }
                     if (data.fatal) {
                         overlay.innerHTML =
                             '<div style="color: #f66;">⚠️ Stream unavailable</div>' +
@@ -1064,8 +1308,11 @@ const htmlTemplate = `<!DOCTYPE html>
             else if (diskUsage >= 90) progressClass = 'critical';
             else if (diskUsage >= 80) progressClass = 'warning';
 
-            container.innerHTML = cameraStorage.map(cam =>
-                '<div class="storage-card">' +
+            container.innerHTML = cameraStorage.map(cam => {
+                const camera = cameras.find(c => c.name === cam.name);
+                return '<div class="storage-card">' +
+                    (camera && camera.snapshot ?
+                        '<img class="storage-thumb" src="' + thumbnailURL(camera) + '" alt="" onerror="this.remove()">' : '') +
                     '<div class="storage-camera-name">' + cam.name + '</div>' +
                     '<div class="storage-detail">' +
                         '<span>Storage Used:</span>' +
@@ -1075,8 +1322,8 @@ const htmlTemplate = `<!DOCTYPE html>
                         '<span>Days Stored:</span>' +
                         '<span class="storage-detail-value">' + cam.days_stored + ' days</span>' +
                     '</div>' +
-                '</div>'
-            ).join('');
+                '</div>';
+            }).join('');
         }
 
         // Playback functionality
@@ -1216,6 +1463,8 @@ const htmlTemplate = `<!DOCTYPE html>
             const visual = document.getElementById('timeline-visual');
             const segments = data.segments || [];
             const gaps = data.gaps || [];
+            const scheduledOff = data.scheduled_off || [];
+            const paused = data.paused || [];
 
             // Get current time for time-aware rendering
             const now = new Date();
@@ -1231,6 +1480,37 @@ const htmlTemplate = `<!DOCTYPE html>
                 html += '<div class="timeline-hour-marker' + (isMajor ? ' major' : '') + '" style="left: ' + left + '%;"></div>';
             }
 
+            // Render time the schedule had recording off (gray bars), under any segments
+            scheduledOff.forEach(off => {
+                const start = timeToMinutes(off.start_time);
+                const end = timeToMinutes(off.end_time);
+                const left = (start / 1440) * 100;
+                const width = ((end - start) / 1440) * 100;
+
+                const tooltip = 'Scheduled off: ' + off.start_time + ' - ' + off.end_time;
+                html += '<div class="timeline-scheduled-off" style="left: ' + left + '%; width: ' + width + '%;"' +
+                    ' title="' + tooltip + '"' +
+                    ' onmouseenter="showTooltip(event, \'' + off.start_time + '\', \'' + off.end_time + '\', \'Outside recording schedule\', false, \'⏰ Scheduled Off\')"' +
+                    ' onmouseleave="hideTooltip()"></div>';
+            });
+
+            // Render time recording was paused (amber bars)
+            paused.forEach(p => {
+                const start = timeToMinutes(p.start_time);
+                const end = Math.min(timeToMinutes(p.end_time), currentMinutes);
+                if (end <= start) {
+                    return;
+                }
+                const left = (start / 1440) * 100;
+                const width = ((end - start) / 1440) * 100;
+
+                const tooltip = 'Paused: ' + p.start_time + ' - ' + p.end_time;
+                html += '<div class="timeline-paused" style="left: ' + left + '%; width: ' + width + '%;"' +
+                    ' title="' + tooltip + '"' +
+                    ' onmouseenter="showTooltip(event, \'' + p.start_time + '\', \'' + p.end_time + '\', \'Recording paused manually\', false, \'⏸️ Paused\')"' +
+                    ' onmouseleave="hideTooltip()"></div>';
+            });
+
             // Render recording segments (green bars) - clickable to play
             segments.forEach(seg => {
                 const start = timeToMinutes(seg.start_time);
@@ -1310,7 +1590,7 @@ const htmlTemplate = `<!DOCTYPE html>
         // Tooltip functions for better interactivity
         let tooltipEl = null;
 
-        function showTooltip(event, startTime, endTime, extra, isGap) {
+        function showTooltip(event, startTime, endTime, extra, isGap, title) {
             hideTooltip();
 
             tooltipEl = document.createElement('div');
@@ -1322,7 +1602,7 @@ const htmlTemplate = `<!DOCTYPE html>
             const icon = isGap ? '⚠️ ' : '✅ ';
             const label = isGap ? 'Recording Gap' : 'Recording';
 
-            tooltipEl.innerHTML = '<strong>' + icon + label + '</strong><br>' +
+            tooltipEl.innerHTML = '<strong>' + (title || icon + label) + '</strong><br>' +
                 startTime + ' → ' + endTime + '<br>' +
                 '<small>' + extra + '</small>';
 
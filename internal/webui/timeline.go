@@ -0,0 +1,97 @@
+package webui
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/config"
+	"github.com/mmuteeullah/CoreNVR/internal/recorder"
+)
+
+// timelinePeriod is a time range on a timeline day
+type timelinePeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// timelineClock places a local time of day on the timeline's date, in the
+// UTC frame the timeline uses for comparing clock times
+func timelineClock(date, t time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// scheduledOffPeriods returns when a camera's schedule had recording off on
+// date. Recording folders follow server local time, so the day does too.
+func scheduledOffPeriods(cam config.CameraConfig, date time.Time) []timelinePeriod {
+	if cam.Schedule == nil {
+		return nil
+	}
+
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	lastSecond := time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 59, 0, time.UTC)
+
+	var periods []timelinePeriod
+	for _, off := range cam.Schedule.OffPeriods(dayStart, dayEnd) {
+		end := lastSecond
+		if off[1].Before(dayEnd) {
+			end = timelineClock(date, off[1])
+		}
+		periods = append(periods, timelinePeriod{Start: timelineClock(date, off[0]), End: end})
+	}
+	return periods
+}
+
+// pausedPeriods returns when a camera's recording was paused on date
+func pausedPeriods(basePath, camera string, date time.Time) []timelinePeriod {
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	lastSecond := time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 59, 0, time.UTC)
+
+	var periods []timelinePeriod
+	for _, p := range recorder.PausedPeriods(basePath, camera, dayStart, dayEnd) {
+		end := lastSecond
+		if p.End.Before(dayEnd) {
+			end = timelineClock(date, p.End.Local())
+		}
+		periods = append(periods, timelinePeriod{Start: timelineClock(date, p.Start.Local()), End: end})
+	}
+	return periods
+}
+
+// mergePeriods sorts periods and joins the ones that overlap
+func mergePeriods(periods []timelinePeriod) []timelinePeriod {
+	sorted := append([]timelinePeriod(nil), periods...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var merged []timelinePeriod
+	for _, p := range sorted {
+		if n := len(merged); n > 0 && !p.Start.After(merged[n-1].End) {
+			if p.End.After(merged[n-1].End) {
+				merged[n-1].End = p.End
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// subtractPeriods returns the parts of gap not covered by periods, which must
+// be sorted and not overlap
+func subtractPeriods(gap timelinePeriod, periods []timelinePeriod) []timelinePeriod {
+	var rest []timelinePeriod
+	for _, p := range periods {
+		if !p.End.After(gap.Start) || !p.Start.Before(gap.End) {
+			continue
+		}
+		if p.Start.After(gap.Start) {
+			rest = append(rest, timelinePeriod{Start: gap.Start, End: p.Start})
+		}
+		gap.Start = p.End
+		if !gap.Start.Before(gap.End) {
+			return rest
+		}
+	}
+	return append(rest, gap)
+}
@@ -1,13 +1,19 @@
 package webui
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+	"github.com/mmuteeullah/CoreNVR/internal/recorder"
 )
 
 // handleStream generates an HLS playlist for a camera
@@ -22,8 +28,10 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	cameraName := parts[2]
 
 	// Check if asking for playlist or segment
-	if strings.HasSuffix(r.URL.Path, ".m3u8") {
-		s.servePlaylist(w, r, cameraName)
+	if strings.HasSuffix(r.URL.Path, "/preview.m3u8") {
+		s.servePlaylist(w, r, cameraName, "preview")
+	} else if strings.HasSuffix(r.URL.Path, ".m3u8") {
+		s.servePlaylist(w, r, cameraName, "live")
 	} else if strings.HasSuffix(r.URL.Path, ".ts") {
 		s.serveSegment(w, r, cameraName)
 	} else {
@@ -31,13 +39,40 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// servePlaylist serves the HLS playlist generated by FFmpeg
-func (s *Server) servePlaylist(w http.ResponseWriter, r *http.Request, cameraName string) {
-	// For live streaming, serve the actual m3u8 file created by FFmpeg
-	playlistPath := filepath.Join(s.config.Storage.BasePath, cameraName, "live", "stream.m3u8")
+// servePlaylist serves a camera's live HLS playlist from memory
+// streamDir is "live" for the live view or "preview" for grid tiles
+func (s *Server) servePlaylist(w http.ResponseWriter, r *http.Request, cameraName, streamDir string) {
+	// Starts an on-demand stream and counts the viewer
+	s.requestStream(r, cameraName, streamDir)
+
+	playlist, ok := "", false
+	if segmenter, found := s.liveSegmenter(cameraName, streamDir); found {
+		// Low-Latency HLS players ask to hold the playlist until a segment
+		// or part they expect next is ready
+		if msn := r.URL.Query().Get("_HLS_msn"); msn != "" {
+			seq, err := strconv.ParseUint(msn, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid _HLS_msn", http.StatusBadRequest)
+				return
+			}
+			part := -1
+			if p := r.URL.Query().Get("_HLS_part"); p != "" {
+				if part, err = strconv.Atoi(p); err != nil || part < 0 {
+					http.Error(w, "Invalid _HLS_part", http.StatusBadRequest)
+					return
+				}
+			}
 
-	// Check if playlist exists
-	if _, err := os.Stat(playlistPath); os.IsNotExist(err) {
+			if !s.awaitLive(r, segmenter, seq, part) {
+				http.Error(w, "Playlist update not available", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		playlist, ok = segmenter.Playlist("/segments/" + cameraName + "/" + streamDir + "/segment")
+	}
+
+	if !ok {
 		// If live stream isn't ready, return a waiting playlist
 		playlist := "#EXTM3U\n"
 		playlist += "#EXT-X-VERSION:3\n"
@@ -50,17 +85,6 @@ func (s *Server) servePlaylist(w http.ResponseWriter, r *http.Request, cameraNam
 		return
 	}
 
-	// Serve the actual playlist file
-	data, err := os.ReadFile(playlistPath)
-	if err != nil {
-		http.Error(w, "Failed to read playlist", http.StatusInternalServerError)
-		return
-	}
-
-	// Update segment paths to work with our URL structure
-	playlistStr := string(data)
-	playlistStr = strings.ReplaceAll(playlistStr, "segment", "/segments/"+cameraName+"/live/segment")
-
 	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	w.Header().Set("Pragma", "no-cache")
@@ -68,7 +92,103 @@ func (s *Server) servePlaylist(w http.ResponseWriter, r *http.Request, cameraNam
 	// CORS headers for Safari mobile
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Write([]byte(playlistStr))
+	w.Write([]byte(playlist))
+}
+
+// serveLiveSegment serves a live segment, or a Low-Latency HLS part named
+// segment<seq>.<part>.ts, from memory
+func (s *Server) serveLiveSegment(w http.ResponseWriter, r *http.Request, cameraName, streamDir, filename string) {
+	name := strings.TrimSuffix(strings.TrimPrefix(filename, "segment"), ".ts")
+	seqStr, partStr, isPart := strings.Cut(name, ".")
+
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	part := 0
+	if err == nil && isPart {
+		part, err = strconv.Atoi(partStr)
+	}
+	if err != nil || part < 0 {
+		http.Error(w, "Invalid segment name", http.StatusBadRequest)
+		return
+	}
+
+	segmenter, ok := s.liveSegmenter(cameraName, streamDir)
+	if !ok {
+		http.Error(w, "Camera not found", http.StatusNotFound)
+		return
+	}
+
+	var data []byte
+	if isPart {
+		// Preload hints point at the next part, so hold the request until
+		// it's ready
+		s.awaitLive(r, segmenter, seq, part)
+		if p, found := segmenter.Part(seq, part); found {
+			data = p.Data
+		}
+	} else if seg, found := segmenter.Segment(seq); found {
+		data = seg.Data
+	}
+	if data == nil {
+		http.Error(w, "Segment not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/MP2T")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	// Segments never change, but are only worth caching while live
+	w.Header().Set("Cache-Control", "max-age=60")
+	// CORS headers for Safari mobile
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+	w.Write(data)
+}
+
+// awaitLive waits until a live stream has a segment, or a part of it if part
+// isn't negative. Like the LL-HLS spec asks, it gives up after three target
+// durations.
+func (s *Server) awaitLive(r *http.Request, segmenter *hls.Segmenter, seq uint64, part int) bool {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*segmenter.TargetDuration())
+	defer cancel()
+	return segmenter.Wait(ctx, seq, part)
+}
+
+// liveSegmenter returns the in-memory HLS segmenter of a camera's live or
+// preview stream
+func (s *Server) liveSegmenter(cameraName, streamDir string) (*hls.Segmenter, bool) {
+	rec, ok := s.cameras.Recorder(cameraName)
+	if !ok {
+		return nil, false
+	}
+
+	pipeline := recorder.PipelineLive
+	if streamDir == "preview" {
+		pipeline = recorder.PipelinePreview
+	}
+	return rec.Segmenter(pipeline)
+}
+
+// requestStream tells a camera's recorder that a client fetched its live or
+// preview stream. Clients are told apart by address and user agent.
+func (s *Server) requestStream(r *http.Request, cameraName, streamDir string) {
+	rec, ok := s.cameras.Recorder(cameraName)
+	if !ok {
+		return
+	}
+
+	pipeline := recorder.PipelineLive
+	if streamDir == "preview" {
+		pipeline = recorder.PipelinePreview
+	}
+	rec.RequestStream(pipeline, streamClient(r))
+}
+
+// streamClient identifies the client behind a stream request
+func streamClient(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host + " " + r.UserAgent()
 }
 
 // handleSegments serves the actual video segments
@@ -93,6 +213,14 @@ func (s *Server) handleSegments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Live segments are held in memory; fetching them keeps an on-demand
+	// stream running
+	if date == "live" || date == "preview" {
+		s.requestStream(r, cameraName, date)
+		s.serveLiveSegment(w, r, cameraName, date, filename)
+		return
+	}
+
 	// Build file path
 	filePath := filepath.Join(s.config.Storage.BasePath, cameraName, date, filename)
 
@@ -0,0 +1,91 @@
+package webui
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mmuteeullah/CoreNVR/internal/hls"
+	"github.com/mmuteeullah/CoreNVR/internal/recorder"
+)
+
+// webrtcStartTimeout is how long signalling waits for an on-demand live
+// stream to start before giving up
+const webrtcStartTimeout = 15 * time.Second
+
+// handleWebRTC negotiates a WebRTC session for a camera's live stream
+// POST /webrtc/{camera} with an SDP offer as the body returns the SDP answer
+func (s *Server) handleWebRTC(w http.ResponseWriter, r *http.Request) {
+	if s.rtc == nil {
+		http.Error(w, "WebRTC is disabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cameraName := strings.Trim(strings.TrimPrefix(r.URL.Path, "/webrtc/"), "/")
+	rec, ok := s.cameras.Recorder(cameraName)
+	if !ok {
+		http.Error(w, "Camera not found", http.StatusNotFound)
+		return
+	}
+	segmenter, _ := rec.Segmenter(recorder.PipelineLive)
+
+	offer, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil || len(offer) == 0 {
+		http.Error(w, "Missing SDP offer", http.StatusBadRequest)
+		return
+	}
+
+	// The session keeps an on-demand live stream running like an HLS player
+	client := streamClient(r)
+	keepAlive := func() { rec.RequestStream(recorder.PipelineLive, client) }
+	keepAlive()
+
+	// Browsers only decode H.264 reliably, so other codecs use HLS
+	codec := s.awaitVideoCodec(r, segmenter)
+	if codec != hls.CodecH264 {
+		if codec == "" {
+			http.Error(w, "Live stream not available", http.StatusServiceUnavailable)
+		} else {
+			http.Error(w, fmt.Sprintf("WebRTC needs H.264, camera sends %s", codec), http.StatusUnsupportedMediaType)
+		}
+		return
+	}
+
+	answer, err := s.rtc.Answer(cameraName, string(offer), segmenter, keepAlive)
+	if err != nil {
+		s.logger.Printf("WebRTC negotiation for %s failed: %v", cameraName, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/sdp")
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(answer))
+}
+
+// awaitVideoCodec waits for a live stream to start and returns its video
+// codec, or "" if it doesn't start in time
+func (s *Server) awaitVideoCodec(r *http.Request, segmenter *hls.Segmenter) string {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(webrtcStartTimeout)
+
+	for {
+		if codec := segmenter.VideoCodec(); codec != "" {
+			return codec
+		}
+		select {
+		case <-r.Context().Done():
+			return ""
+		case <-deadline:
+			return ""
+		case <-ticker.C:
+		}
+	}
+}